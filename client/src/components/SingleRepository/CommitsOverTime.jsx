@@ -1,6 +1,7 @@
 // eslint-disable-next-line
 import React from 'react'
 import PropTypes from 'prop-types'
+import Graph from './Graph'
 import ValueAndPercentage from './ValueAndPercentage'
 
 const CommitsOverTime = props =>
@@ -29,6 +30,9 @@ const CommitsOverTime = props =>
               />
             </div>
           </div>
+          <div className="el-chart-w">
+            <Graph labels={props.graph_labels} data={props.graph_data} label={props.graph_label} />
+          </div>
         </div>
         <div className="tab-pane" id="tab_conversion" />
       </div>
@@ -40,6 +44,15 @@ CommitsOverTime.propTypes = {
   count_last_12_months: PropTypes.number.isRequired,
   count_last_4_weeks: PropTypes.number.isRequired,
   count_last_week: PropTypes.number.isRequired,
+  graph_label: PropTypes.string,
+  graph_labels: PropTypes.arrayOf(PropTypes.string),
+  graph_data: PropTypes.arrayOf(PropTypes.number),
+}
+
+CommitsOverTime.defaultProps = {
+  graph_label: null,
+  graph_labels: null,
+  graph_data: null,
 }
 
 export default CommitsOverTime
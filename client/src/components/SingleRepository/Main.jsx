@@ -39,6 +39,13 @@ class SingleRepository extends Component {
     if (data.repository.stars_per_month) {
       starsPerMonth = JSON.parse(data.repository.stars_per_month)
     }
+    let commitsPerMonth = {
+      labels: null,
+      data: null,
+    }
+    if (data.repository.commits_per_month) {
+      commitsPerMonth = JSON.parse(data.repository.commits_per_month)
+    }
 
     return (
       <div>
@@ -61,6 +68,9 @@ class SingleRepository extends Component {
               count_last_12_months={data.repository.commits_count_last_12_months}
               count_last_4_weeks={data.repository.commits_count_last_4_weeks}
               count_last_week={data.repository.commits_count_last_week}
+              graph_label={'Commits up to now'}
+              graph_labels={commitsPerMonth.labels}
+              graph_data={commitsPerMonth.data}
             />
             <StarsOverTime
               total={data.repository.total_stars}
@@ -96,6 +106,7 @@ SingleRepository.propTypes = {
       stars_count_last_4_weeks: PropTypes.number,
       stars_count_last_week: PropTypes.number,
       stars_per_month: PropTypes.string,
+      commits_per_month: PropTypes.string,
     }),
   }),
 }
@@ -13,3 +13,45 @@ type ErrRepoNotFound string
 func (e ErrRepoNotFound) Error() string {
 	return string(e)
 }
+
+type ErrJobNotFound string
+
+func (e ErrJobNotFound) Error() string {
+	return string(e)
+}
+
+type ErrQueueFull string
+
+func (e ErrQueueFull) Error() string {
+	return string(e)
+}
+
+type ErrUpstreamNotFound string
+
+func (e ErrUpstreamNotFound) Error() string {
+	return string(e)
+}
+
+type ErrRateLimited string
+
+func (e ErrRateLimited) Error() string {
+	return string(e)
+}
+
+type ErrUpstreamUnavailable string
+
+func (e ErrUpstreamUnavailable) Error() string {
+	return string(e)
+}
+
+type ErrBadRequest string
+
+func (e ErrBadRequest) Error() string {
+	return string(e)
+}
+
+type ErrMethodNotAllowed string
+
+func (e ErrMethodNotAllowed) Error() string {
+	return string(e)
+}
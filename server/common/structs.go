@@ -1,24 +1,131 @@
 package common
 
+import "time"
+
 // Repository contains the details of a repository
 type Repository struct {
-	ID                       int    `json:"id"`
-	Name                     string `json:"name"`
-	OwnerName                string `json:"ownerName"`
-	RepoAge                  int    `json:"repository_created_months_ago"`
-	Initialized              bool   `json:"initialized"`
-	TotalStars               int    `json:"total_stars"`
-	TotalCommits             int    `json:"total_commits"`
-	Description              string `json:"description"`
-	CreatedAt                string `json:"created_at"`
-	DefaultBranch            string `json:"default_branch"`
-	CommitsCountLast12Months int    `json:"commits_count_last_12_months"`
-	CommitsCountLast4Weeks   int    `json:"commits_count_last_4_weeks"`
-	CommitsCountLastWeek     int    `json:"commits_count_last_week"`
-	StarsCountLast12Months   int    `json:"stars_count_last_12_months"`
-	StarsCountLast4Weeks     int    `json:"stars_count_last_4_weeks"`
-	StarsCountLastWeek       int    `json:"stars_count_last_week"`
-	StarsPerMonth            string `json:"stars_per_month"`
+	ID                        int            `json:"id"`
+	Name                      string         `json:"name"`
+	OwnerName                 string         `json:"ownerName"`
+	RepoAge                   int            `json:"repository_created_months_ago"`
+	Initialized               bool           `json:"initialized"`
+	TotalStars                int            `json:"total_stars"`
+	TotalCommits              int            `json:"total_commits"`
+	Description               string         `json:"description"`
+	CreatedAt                 string         `json:"created_at"`
+	DefaultBranch             string         `json:"default_branch"`
+	CommitsCountLast12Months  int            `json:"commits_count_last_12_months"`
+	CommitsCountLast4Weeks    int            `json:"commits_count_last_4_weeks"`
+	CommitsCountLastWeek      int            `json:"commits_count_last_week"`
+	StarsCountLast12Months    int            `json:"stars_count_last_12_months"`
+	StarsCountLast4Weeks      int            `json:"stars_count_last_4_weeks"`
+	StarsCountLastWeek        int            `json:"stars_count_last_week"`
+	StarsPerMonth             string         `json:"stars_per_month"` // Deprecated: use StarsHistory
+	UnstarsCountLast12Months  int            `json:"unstars_count_last_12_months"`
+	UnstarsCountLast4Weeks    int            `json:"unstars_count_last_4_weeks"`
+	UnstarsCountLastWeek      int            `json:"unstars_count_last_week"`
+	NetStarsCountLast12Months int            `json:"net_stars_count_last_12_months"`
+	NetStarsCountLast4Weeks   int            `json:"net_stars_count_last_4_weeks"`
+	NetStarsCountLastWeek     int            `json:"net_stars_count_last_week"`
+	StarsGrowthPerWeek        []StarGrowth   `json:"stars_growth_per_week"`
+	StarsGrowthPerMonth       []StarGrowth   `json:"stars_growth_per_month"`
+	StarsHistory              []StarBucket   `json:"stars_history"`
+	Fork                      bool           `json:"fork"`
+	TotalForks                int            `json:"total_forks"`
+	ForksCountLast12Months    int            `json:"forks_count_last_12_months"`
+	ForksCountLast4Weeks      int            `json:"forks_count_last_4_weeks"`
+	ForksCountLastWeek        int            `json:"forks_count_last_week"`
+	ForksPerMonth             string         `json:"forks_per_month"`
+	CommitsPerMonth           string         `json:"commits_per_month"`
+	TotalIssuesOpened         int            `json:"total_issues_opened"`
+	TotalPullRequests         int            `json:"total_pull_requests"`
+	Issues                    IssueStats     `json:"issues"`
+	Releases                  ReleaseStats   `json:"releases"`
+	Trends                    []CounterTrend `json:"trends"`
+}
+
+// StarGrowth contains the stars a repository gained and lost in a
+// period, e.g. `2017-08` or `2017-W35`
+type StarGrowth struct {
+	Period  string `json:"period"`
+	Gross   int    `json:"gross"`
+	Unstars int    `json:"unstars"`
+	Net     int    `json:"net"`
+}
+
+// CounterTrend compares a counter of a repository over a window ending
+// now with the same counter over the window before. PercentChange is nil
+// when the counter was 0 in the previous window
+type CounterTrend struct {
+	Counter       string   `json:"counter"`
+	Window        string   `json:"window"`
+	Current       int      `json:"current"`
+	Previous      int      `json:"previous"`
+	Change        int      `json:"change"`
+	PercentChange *float64 `json:"percent_change"`
+	Trend         string   `json:"trend"`
+}
+
+// ForecastPoint contains the value a model projects for the end of a
+// period, with the bounds of its 95% confidence band
+type ForecastPoint struct {
+	Period string  `json:"period"`
+	Value  float64 `json:"value"`
+	Lower  float64 `json:"lower"`
+	Upper  float64 `json:"upper"`
+}
+
+// ForecastModel contains the projection of a model fitted on the monthly
+// history of a counter. TargetDate is nil when no target was asked or
+// the model never reaches it
+type ForecastModel struct {
+	Model      string          `json:"model"`
+	R2         float64         `json:"r2"`
+	Points     []ForecastPoint `json:"points"`
+	TargetDate *time.Time      `json:"target_date"`
+}
+
+// Forecast contains the projections of a counter of a repository
+// returned by the API call. Best is the model fitting the history best
+type Forecast struct {
+	Name      string          `json:"name"`
+	OwnerName string          `json:"ownerName"`
+	Metric    string          `json:"metric"`
+	Horizon   int             `json:"horizon_months"`
+	Current   int             `json:"current"`
+	Target    int             `json:"target,omitempty"`
+	ReachedAt *time.Time      `json:"reached_at,omitempty"`
+	Best      string          `json:"best"`
+	Models    []ForecastModel `json:"models"`
+}
+
+// StarBucket contains the stars a repository gained in a period, net of
+// the unstars, and its stars at the end of the period
+type StarBucket struct {
+	Period     string       `json:"period"`
+	Start      time.Time    `json:"start"`
+	Count      int          `json:"count"`
+	Cumulative int          `json:"cumulative"`
+	Events     []SpikeEvent `json:"events,omitempty"`
+}
+
+// SpikeEvent contains a day a counter of a repository jumped well above
+// its usual level. Baseline is the median of the days before it, and
+// Magnitude how many deviations above the baseline the day is
+type SpikeEvent struct {
+	Metric    string    `json:"metric"`
+	Date      time.Time `json:"date"`
+	Count     int       `json:"count"`
+	Baseline  float64   `json:"baseline"`
+	Magnitude float64   `json:"magnitude"`
+}
+
+// RepositoryEvents contains the spikes of a repository returned by the
+// API call
+type RepositoryEvents struct {
+	Name      string       `json:"name"`
+	OwnerName string       `json:"ownerName"`
+	Events    []SpikeEvent `json:"events"`
 }
 
 // RepoData contains the aggregate repository data returned
@@ -29,13 +136,334 @@ type RepoData struct {
 
 // RepositorySummary contains the details of a repository
 type RepositorySummary struct {
-	ID         int    `json:"id"`
-	Name       string `json:"name"`
-	OwnerName  string `json:"ownerName"`
-	TotalStars int    `json:"totalStars"`
+	ID                   int        `json:"id"`
+	Name                 string     `json:"name"`
+	OwnerName            string     `json:"ownerName"`
+	Description          string     `json:"description"`
+	TotalStars           int        `json:"totalStars"`
+	TotalCommits         int        `json:"totalCommits"`
+	TotalForks           int        `json:"totalForks"`
+	StarsCountLastWeek   int        `json:"starsCountLastWeek"`
+	StarsCountLast4Weeks int        `json:"starsCountLast4Weeks"`
+	AddedAt              *time.Time `json:"addedAt"`
 }
 
-// Repositories contains a slice of repositories
+// Repositories contains a page of repositories, and the cursor of the
+// next page if there is one
 type Repositories struct {
 	Repositories []RepositorySummary `json:"repositories"`
+	NextCursor   string              `json:"next_cursor,omitempty"`
+}
+
+// RepoListOptions selects and orders the repositories listed by the
+// index. Cursor is the NextCursor of the previous page, Query matches a
+// substring of the name or the description
+type RepoListOptions struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Order  string
+	Owner  string
+	Query  string
+}
+
+// OwnerRepository contains the counters of a repository compared in the
+// overview of its owner
+type OwnerRepository struct {
+	Name                     string `json:"name"`
+	TotalStars               int    `json:"total_stars"`
+	TotalCommits             int    `json:"total_commits"`
+	TotalForks               int    `json:"total_forks"`
+	StarsCountLast4Weeks     int    `json:"stars_count_last_4_weeks"`
+	CommitsCountLast4Weeks   int    `json:"commits_count_last_4_weeks"`
+	CommitsCountLast12Months int    `json:"commits_count_last_12_months"`
+}
+
+// OwnerStats contains the overview of the tracked repositories of an
+// owner returned by the API call. Stars and commits by month are the
+// counts of each month, not the cumulative graphs of Repository
+type OwnerStats struct {
+	OwnerName         string            `json:"ownerName"`
+	TotalRepositories int               `json:"total_repositories"`
+	TotalStars        int               `json:"total_stars"`
+	TotalCommits      int               `json:"total_commits"`
+	TotalForks        int               `json:"total_forks"`
+	StarsByMonth      []PeriodCount     `json:"stars_by_month"`
+	CommitsByMonth    []PeriodCount     `json:"commits_by_month"`
+	TopGrowers        []OwnerRepository `json:"top_growers"`
+	LeastActive       []OwnerRepository `json:"least_active"`
+}
+
+// RepositorySnapshot contains the counters of a repository as they
+// were recorded at a given point in time
+type RepositorySnapshot struct {
+	RecordedAt               time.Time `json:"recorded_at"`
+	TotalStars               int       `json:"total_stars"`
+	TotalCommits             int       `json:"total_commits"`
+	CommitsCountLast12Months int       `json:"commits_count_last_12_months"`
+	CommitsCountLast4Weeks   int       `json:"commits_count_last_4_weeks"`
+	CommitsCountLastWeek     int       `json:"commits_count_last_week"`
+	StarsCountLast12Months   int       `json:"stars_count_last_12_months"`
+	StarsCountLast4Weeks     int       `json:"stars_count_last_4_weeks"`
+	StarsCountLastWeek       int       `json:"stars_count_last_week"`
+}
+
+// RepositoryHistory contains the time series of snapshots of a
+// repository, oldest first
+type RepositoryHistory struct {
+	Name      string               `json:"name"`
+	OwnerName string               `json:"ownerName"`
+	Snapshots []RepositorySnapshot `json:"snapshots"`
+}
+
+// ActivityWindow contains what happened to a repository between From
+// and To. Stars include the ones removed since
+type ActivityWindow struct {
+	Window       string    `json:"window"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Stars        int       `json:"stars"`
+	Unstars      int       `json:"unstars"`
+	Commits      int       `json:"commits"`
+	Forks        int       `json:"forks"`
+	Issues       int       `json:"issues"`
+	PullRequests int       `json:"pull_requests"`
+}
+
+// RepositoryActivity contains the activity of a repository in the
+// windows requested by the API call
+type RepositoryActivity struct {
+	Name      string           `json:"name"`
+	OwnerName string           `json:"ownerName"`
+	Windows   []ActivityWindow `json:"windows"`
+}
+
+// RefreshStatus contains the periodic refresh state of a tracked
+// repository
+type RefreshStatus struct {
+	Name          string     `json:"name"`
+	OwnerName     string     `json:"ownerName"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextRunAt     *time.Time `json:"next_run_at"`
+	Running       bool       `json:"running"`
+}
+
+// SchedulerStatus contains the state of the background refresh
+// scheduler returned by the API call
+type SchedulerStatus struct {
+	Enabled      bool            `json:"enabled"`
+	Interval     string          `json:"interval"`
+	Jitter       string          `json:"jitter"`
+	StartedAt    *time.Time      `json:"started_at"`
+	Repositories []RefreshStatus `json:"repositories"`
+}
+
+// JobState is the state of an import job
+type JobState string
+
+// The states an import job goes through
+const (
+	JobQueued   JobState = "queued"
+	JobRunning  JobState = "running"
+	JobDone     JobState = "done"
+	JobFailed   JobState = "failed"
+	JobCanceled JobState = "canceled"
+)
+
+// Job contains the details of a repository import job. The pages
+// fetched and their total are the ones of the current phase of the import
+type Job struct {
+	ID           string     `json:"id"`
+	Owner        string     `json:"owner"`
+	Name         string     `json:"name"`
+	Refresh      bool       `json:"refresh"`
+	State        JobState   `json:"state"`
+	Phase        string     `json:"phase,omitempty"`
+	PagesFetched int        `json:"pages_fetched"`
+	PagesTotal   int        `json:"pages_total"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+
+	// Err is the error a failed job failed with, keeping its type
+	Err error `json:"-"`
+}
+
+// AccountRepo contains a repository listed for a GitHub user or
+// organization
+type AccountRepo struct {
+	Owner    string
+	Name     string
+	Fork     bool
+	Archived bool
+	Private  bool
+}
+
+// ImportOptions filters the repositories of an account to import. Name
+// is a regular expression the repository names must match
+type ImportOptions struct {
+	SkipForks      bool   `json:"skip_forks"`
+	SkipArchived   bool   `json:"skip_archived"`
+	IncludePrivate bool   `json:"include_private"`
+	Name           string `json:"name"`
+}
+
+// SkippedRepo contains a repository left out of an import, and why
+type SkippedRepo struct {
+	Owner  string `json:"owner"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult contains the jobs created importing the repositories of
+// an account, and the repositories left out
+type ImportResult struct {
+	Added   []Job         `json:"added"`
+	Skipped []SkippedRepo `json:"skipped"`
+}
+
+// RateLimitBudget contains the GitHub API requests left until the
+// rate limit resets
+type RateLimitBudget struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// RateLimitStatus contains the GitHub API budget returned by the
+// API call
+type RateLimitStatus struct {
+	Core        RateLimitBudget `json:"core"`
+	Search      RateLimitBudget `json:"search"`
+	PausedUntil *time.Time      `json:"paused_until"`
+}
+
+// Stargazer contains a user who starred a repository, and when
+type Stargazer struct {
+	Login     string    `json:"login"`
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// Unstar contains a user who unstarred a repository. UnstarredAt is when
+// gitometer noticed it, GitHub does not tell when it happened
+type Unstar struct {
+	Login       string    `json:"login"`
+	StarredAt   time.Time `json:"starred_at"`
+	UnstarredAt time.Time `json:"unstarred_at"`
+}
+
+// Fork contains a fork of a repository
+type Fork struct {
+	ID        int64     `json:"id"`
+	FullName  string    `json:"full_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Commit contains a commit on the default branch of a repository
+type Commit struct {
+	SHA         string    `json:"sha"`
+	Author      string    `json:"author"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// Contributor contains a contributor of a repository and how many
+// commits GitHub credits them with
+type Contributor struct {
+	Login         string `json:"login"`
+	Contributions int    `json:"contributions"`
+}
+
+// ContributorWeek contains how many commits a contributor authored in
+// the week starting at Week
+type ContributorWeek struct {
+	Login   string    `json:"login"`
+	Week    time.Time `json:"week"`
+	Commits int       `json:"commits"`
+}
+
+// ContributorCommits contains how many commits a contributor authored in
+// a window of time
+type ContributorCommits struct {
+	Login   string `json:"login"`
+	Commits int    `json:"commits"`
+}
+
+// ContributorStats contains the contributors analytics of a repository.
+// Top contributors and bus factor cover the last 12 months
+type ContributorStats struct {
+	Name                    string               `json:"name"`
+	OwnerName               string               `json:"ownerName"`
+	TotalContributors       int                  `json:"total_contributors"`
+	NewContributorsPerMonth []PeriodCount        `json:"new_contributors_per_month"`
+	TopContributors         []ContributorCommits `json:"top_contributors"`
+	BusFactor               int                  `json:"bus_factor"`
+}
+
+// Issue contains an issue or a pull request of a repository
+type Issue struct {
+	Number          int        `json:"number"`
+	PullRequest     bool       `json:"pull_request"`
+	Title           string     `json:"title"`
+	Author          string     `json:"author"`
+	State           string     `json:"state"`
+	Labels          []string   `json:"labels"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	ClosedAt        *time.Time `json:"closed_at"`
+	MergedAt        *time.Time `json:"merged_at"`
+	FirstResponseAt *time.Time `json:"first_response_at"`
+}
+
+// IssueComment contains who commented an issue or a pull request, and when
+type IssueComment struct {
+	Number    int       `json:"number"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PeriodCount contains how many events happened in a period
+type PeriodCount struct {
+	Period string `json:"period"`
+	Count  int    `json:"count"`
+}
+
+// IssueStats contains the issues and pull requests analytics of a
+// repository. Times to close and to first response are measured on
+// issues only, pull requests are measured by their merge rate
+type IssueStats struct {
+	OpenIssues                     int           `json:"open_issues"`
+	ClosedIssues                   int           `json:"closed_issues"`
+	OpenPullRequests               int           `json:"open_pull_requests"`
+	ClosedPullRequests             int           `json:"closed_pull_requests"`
+	MergedPullRequests             int           `json:"merged_pull_requests"`
+	PullRequestMergeRate           float64       `json:"pull_request_merge_rate"`
+	MedianTimeToCloseHours         float64       `json:"median_time_to_close_hours"`
+	MedianTimeToFirstResponseHours float64       `json:"median_time_to_first_response_hours"`
+	IssuesOpenedPerMonth           []PeriodCount `json:"issues_opened_per_month"`
+}
+
+// Release contains a release or a tag of a repository. Tags with no
+// GitHub release have no PublishedAt
+type Release struct {
+	Tag         string     `json:"tag"`
+	Name        string     `json:"name"`
+	PublishedAt *time.Time `json:"published_at"`
+	Prerelease  bool       `json:"prerelease"`
+	Downloads   int        `json:"downloads"`
+}
+
+// ReleaseStats contains the release cadence of a repository. Releases
+// per quarter and average days between releases ignore prereleases.
+// Total tags counts every tag, the ones releases were published for
+// included
+type ReleaseStats struct {
+	LatestRelease              *Release      `json:"latest_release"`
+	TotalReleases              int           `json:"total_releases"`
+	TotalTags                  int           `json:"total_tags"`
+	ReleasesPerQuarter         []PeriodCount `json:"releases_per_quarter"`
+	AverageDaysBetweenReleases float64       `json:"average_days_between_releases"`
+	TotalDownloads             int           `json:"total_downloads"`
 }
@@ -1,42 +1,91 @@
 package db
 
 import (
-	"database/sql"
 	"fmt"
-	"log"
 	"os"
-
-	// Postgres drivers
-	_ "github.com/lib/pq"
+	"time"
 
 	"github.com/flaviocopes/gitometer/server/common"
 )
 
 const (
-	dbhost = "DBHOST"
-	dbport = "DBPORT"
-	dbuser = "DBUSER"
-	dbpass = "DBPASS"
-	dbname = "DBNAME"
+	dbdriver = "GITOMETER_DB_DRIVER"
+
+	postgresDialect = "postgres"
+	sqliteDialect   = "sqlite3"
 )
 
-var db *sql.DB
+// Store is implemented by the storage backends gitometer can keep its
+// data in
+type Store interface {
+	QueryRepos(opt common.RepoListOptions, repos *common.Repositories) error
+	QueryOwnerRepos(owner string, repos *[]common.OwnerRepository) error
+	QueryOwnerStarDates(owner string, dates *[]time.Time) error
+	QueryOwnerCommitDates(owner string, dates *[]time.Time) error
+	AddNewRepo(owner, name string, repo *common.Repository) error
+	UpdateRepo(owner, name string, repo *common.Repository) error
+	FetchRepo(repo *common.Repository, data *common.RepoData) error
+	AddRepoSnapshot(owner, name string, repo *common.Repository) error
+	QueryRepoHistory(owner, name string, from, to time.Time, history *common.RepositoryHistory) error
+	RepoExists(owner, name string) (bool, error)
+	DeleteRepo(owner, name string) error
+	SetRepoEnabled(owner, name string, enabled bool) error
+	CountActivity(owner, name string, window *common.ActivityWindow) error
+	QueryEnabledRepos(repos *[]common.RefreshStatus) error
+	SetRefreshSuccess(owner, name string, at time.Time) error
+	SetRefreshFailure(owner, name string, at time.Time, cause error) error
+	LatestStarredAt(owner, name string) (time.Time, error)
+	AddStargazers(owner, name string, stargazers []common.Stargazer) error
+	QueryStarDates(owner, name string, dates *[]time.Time) error
+	QueryStargazers(owner, name string, stargazers *[]common.Stargazer) error
+	AddUnstars(owner, name string, unstars []common.Unstar) error
+	UnstarsCheckedAt(owner, name string) (time.Time, error)
+	SetUnstarsChecked(owner, name string, at time.Time) error
+	QueryUnstars(owner, name string, unstars *[]common.Unstar) error
+	ReplaceStarBuckets(owner, name, granularity string, buckets []common.StarBucket) error
+	QueryStarBuckets(owner, name, granularity string, buckets *[]common.StarBucket) error
+	ReplaceSpikeEvents(owner, name string, events []common.SpikeEvent) error
+	QuerySpikeEvents(owner, name string, events *[]common.SpikeEvent) error
+	LatestForkCreatedAt(owner, name string) (time.Time, error)
+	AddForks(owner, name string, forks []common.Fork) error
+	QueryForkDates(owner, name string, dates *[]time.Time) error
+	QueryCommitSHAs(owner, name string, shas *[]string) error
+	AddCommits(owner, name string, commits []common.Commit) error
+	QueryCommitDates(owner, name string, dates *[]time.Time) error
+	AddContributors(owner, name string, contributors []common.Contributor) error
+	AddContributorWeeks(owner, name string, weeks []common.ContributorWeek) error
+	CountContributors(owner, name string) (int, error)
+	QueryContributorWeeks(owner, name string, weeks *[]common.ContributorWeek) error
+	AddReleases(owner, name string, releases []common.Release) error
+	QueryReleases(owner, name string, releases *[]common.Release) error
+	IssuesSyncedAt(owner, name string) (time.Time, error)
+	SetIssuesSynced(owner, name string, at time.Time) error
+	AddIssues(owner, name string, issues []common.Issue) error
+	AddPullRequestMerges(owner, name string, merges map[int]time.Time) error
+	AddIssueComments(owner, name string, comments []common.IssueComment) error
+	QueryIssues(owner, name string, issues *[]common.Issue) error
+	Migrations() ([]Migration, error)
+	MigrateUp() error
+	MigrateDown(steps int) error
+	Close() error
+}
+
+var store Store
 
-// InitDb ...
+// InitDb opens the storage backend selected by the GITOMETER_DB_DRIVER
+// environment variable, `postgres` (the default) or `sqlite3`
 func InitDb() {
-	if db == nil {
-		config := dbConfig()
+	if store == nil {
+		driver := os.Getenv(dbdriver)
 		var err error
-		psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
-			"password='%s' dbname=%s sslmode=disable",
-			config[dbhost], config[dbport],
-			config[dbuser], config[dbpass], config[dbname])
-
-		db, err = sql.Open("postgres", psqlInfo)
-		if err != nil {
-			panic(err)
+		switch driver {
+		case "", postgresDialect:
+			store, err = newPostgresStore()
+		case sqliteDialect:
+			store, err = newSQLiteStore()
+		default:
+			err = fmt.Errorf("Unknown %s %q, expecting %s or %s", dbdriver, driver, postgresDialect, sqliteDialect)
 		}
-		err = db.Ping()
 		if err != nil {
 			panic(err)
 		}
@@ -46,231 +95,292 @@ func InitDb() {
 
 // Close closes the db connection (called via defer)
 func Close() {
-	db.Close()
+	store.Close()
 }
 
-func dbConfig() map[string]string {
-	conf := make(map[string]string)
-	host, ok := os.LookupEnv(dbhost)
-	if !ok {
-		panic("DBHOST environment variable required but not set")
-	}
-	port, ok := os.LookupEnv(dbport)
-	if !ok {
-		panic("DBPORT environment variable required but not set")
-	}
-	user, ok := os.LookupEnv(dbuser)
-	if !ok {
-		panic("DBUSER environment variable required but not set")
-	}
-	password, ok := os.LookupEnv(dbpass)
-	if !ok {
-		panic("DBPASS environment variable required but not set")
-	}
-	name, ok := os.LookupEnv(dbname)
-	if !ok {
-		panic("DBNAME environment variable required but not set")
-	}
-	conf[dbhost] = host
-	conf[dbport] = port
-	conf[dbuser] = user
-	conf[dbpass] = password
-	conf[dbname] = name
-	return conf
-}
-
-// QueryRepos first fetches the repositories data from the db
-func QueryRepos(repos *common.Repositories) error {
-	rows, err := db.Query(`
-		SELECT
-			id,
-			repository_owner,
-			repository_name,
-			total_stars
-		FROM repositories
-		ORDER BY total_stars DESC`)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-	for rows.Next() {
-		repo := common.RepositorySummary{}
-		err = rows.Scan(
-			&repo.ID,
-			&repo.OwnerName,
-			&repo.Name,
-			&repo.TotalStars,
-		)
-		if err != nil {
-			return err
-		}
-		repos.Repositories = append(repos.Repositories, repo)
-	}
-	err = rows.Err()
-	if err != nil {
-		return err
-	}
-	return nil
+// Migrations lists the schema migrations and whether they were applied
+func Migrations() ([]Migration, error) {
+	return store.Migrations()
 }
 
-// AddNewRepo adds a repository to the db
-func AddNewRepo(owner, name string, repo *common.Repository) error {
-	var id int
-
-	err := db.QueryRow("SELECT id_of_repository_on_github FROM repositories WHERE repository_owner=$1 AND repository_name=$2", owner, name).Scan(&id)
-	switch {
-	case err == sql.ErrNoRows:
-		// Repo is new
-
-		sqlStatement := `
-			INSERT INTO repositories (
-				id_of_repository_on_github,
-				repository_name,
-				repository_owner,
-				default_branch,
-				created_at,
-				description,
-				repository_created_months_ago,
-				total_stars,
-				total_commits,
-				commits_count_last_12_months,
-				commits_count_last_4_weeks,
-				commits_count_last_week,
-				stars_count_last_12_months,
-				stars_count_last_4_weeks,
-				stars_count_last_week,
-				stars_per_month
-				)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
-		_, err := db.Exec(
-			sqlStatement,
-			repo.ID,
-			repo.Name,
-			repo.OwnerName,
-			repo.DefaultBranch,
-			repo.CreatedAt,
-			repo.Description,
-			repo.RepoAge,
-			repo.TotalStars,
-			repo.TotalCommits,
-			repo.CommitsCountLast12Months,
-			repo.CommitsCountLast4Weeks,
-			repo.CommitsCountLastWeek,
-			repo.StarsCountLast12Months,
-			repo.StarsCountLast4Weeks,
-			repo.StarsCountLastWeek,
-			repo.StarsPerMonth,
-		)
+// MigrateUp brings the schema up to date
+func MigrateUp() error {
+	return store.MigrateUp()
+}
 
-		if err != nil {
-			log.Fatal(err)
-		}
+// MigrateDown reverts the last `steps` applied schema migrations
+func MigrateDown(steps int) error {
+	return store.MigrateDown(steps)
+}
 
-	case err != nil:
-		log.Fatal(err)
-	default:
-		sqlStatement := `
-			UPDATE repositories SET
-				stars_per_month = $1,
-				default_branch = $2,
-				description = $3,
-				repository_created_months_ago = $4,
-				total_stars = $5,
-				total_commits = $6,
-				commits_count_last_12_months = $7,
-				commits_count_last_4_weeks = $8,
-				commits_count_last_week = $9,
-				stars_count_last_12_months = $10,
-				stars_count_last_4_weeks = $11,
-				stars_count_last_week = $12
-			WHERE id_of_repository_on_github = $13`
-		_, err := db.Exec(
-			sqlStatement,
-			repo.StarsPerMonth,
-			repo.DefaultBranch,
-			repo.Description,
-			repo.RepoAge,
-			repo.TotalStars,
-			repo.TotalCommits,
-			repo.CommitsCountLast12Months,
-			repo.CommitsCountLast4Weeks,
-			repo.CommitsCountLastWeek,
-			repo.StarsCountLast12Months,
-			repo.StarsCountLast4Weeks,
-			repo.StarsCountLastWeek,
-			id,
-		)
+// QueryRepos fetches the page of repositories selected by `opt`
+func QueryRepos(opt common.RepoListOptions, repos *common.Repositories) error {
+	return store.QueryRepos(opt, repos)
+}
 
-		if err != nil {
-			log.Fatal(err)
-		}
+// QueryOwnerRepos fetches the counters of the repositories of an owner
+func QueryOwnerRepos(owner string, repos *[]common.OwnerRepository) error {
+	return store.QueryOwnerRepos(owner, repos)
+}
 
-	}
+// QueryOwnerStarDates fetches when each star of the repositories of an
+// owner was given
+func QueryOwnerStarDates(owner string, dates *[]time.Time) error {
+	return store.QueryOwnerStarDates(owner, dates)
+}
 
-	return nil
+// QueryOwnerCommitDates fetches when each commit of the repositories of
+// an owner was committed
+func QueryOwnerCommitDates(owner string, dates *[]time.Time) error {
+	return store.QueryOwnerCommitDates(owner, dates)
+}
+
+// AddNewRepo adds a repository to the db
+func AddNewRepo(owner, name string, repo *common.Repository) error {
+	return store.AddNewRepo(owner, name, repo)
+}
+
+// UpdateRepo updates the data of a repository already in the db, and
+// returns common.ErrRepoNotFound when the repository is not there
+func UpdateRepo(owner, name string, repo *common.Repository) error {
+	return store.UpdateRepo(owner, name, repo)
 }
 
 // FetchRepo given a Repository value with name and owner of the repo
 // fetches more details from the database and fills the value with more
 // data
 func FetchRepo(repo *common.Repository, data *common.RepoData) error {
-	if len(repo.Name) == 0 {
-		return fmt.Errorf("Repository name not correctly set")
-	}
-	if len(repo.OwnerName) == 0 {
-		return fmt.Errorf("Repository owner not correctly set")
-	}
-	sqlStatement := `
-		SELECT
-			id,
-			initialized,
-			repository_created_months_ago,
-			total_stars,
-			total_commits,
-			description,
-			commits_count_last_12_months,
-			commits_count_last_4_weeks,
-			commits_count_last_week,
-			stars_count_last_12_months,
-			stars_count_last_4_weeks,
-			stars_count_last_week,
-			stars_per_month
-		FROM repositories
-		WHERE repository_owner=$1 and repository_name=$2
-		LIMIT 1;`
-	row := db.QueryRow(sqlStatement, repo.OwnerName, repo.Name)
-	err := row.Scan(
-		&repo.ID,
-		&repo.Initialized,
-		&repo.RepoAge,
-		&repo.TotalStars,
-		&repo.TotalCommits,
-		&repo.Description,
-		&repo.CommitsCountLast12Months,
-		&repo.CommitsCountLast4Weeks,
-		&repo.CommitsCountLastWeek,
-		&repo.StarsCountLast12Months,
-		&repo.StarsCountLast4Weeks,
-		&repo.StarsCountLastWeek,
-		&repo.StarsPerMonth)
-	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			//locally handle SQL error, abstract for caller
-			return common.ErrRepoNotFound("Repository not found")
-		default:
-			return err
-		}
-	}
-	if !repo.Initialized {
-		return common.ErrRepoNotInitialized("Repository not initialized")
-	}
-	if repo.RepoAge < 3 {
-		return common.ErrRepoNotInitialized("Repository not initialized")
-	}
+	return store.FetchRepo(repo, data)
+}
+
+// AddRepoSnapshot appends a timestamped snapshot of the repository
+// counters to the history of the repository
+func AddRepoSnapshot(owner, name string, repo *common.Repository) error {
+	return store.AddRepoSnapshot(owner, name, repo)
+}
+
+// QueryRepoHistory fetches the snapshots of a repository recorded
+// between `from` and `to`. A zero time.Time leaves that end of the
+// range open
+func QueryRepoHistory(owner, name string, from, to time.Time, history *common.RepositoryHistory) error {
+	return store.QueryRepoHistory(owner, name, from, to, history)
+}
+
+// RepoExists reports whether the repository is tracked
+func RepoExists(owner, name string) (bool, error) {
+	return store.RepoExists(owner, name)
+}
+
+// DeleteRepo removes a repository, along with its snapshots, stargazers
+// and everything else stored about it
+func DeleteRepo(owner, name string) error {
+	return store.DeleteRepo(owner, name)
+}
+
+// SetRepoEnabled includes a repository in the periodic refreshes, or
+// excludes it while keeping its data
+func SetRepoEnabled(owner, name string, enabled bool) error {
+	return store.SetRepoEnabled(owner, name, enabled)
+}
+
+// CountActivity fills the counters of `window` with the stars, commits,
+// forks, issues and pull requests of the repository in the window
+func CountActivity(owner, name string, window *common.ActivityWindow) error {
+	return store.CountActivity(owner, name, window)
+}
+
+// QueryEnabledRepos fetches the repositories that should be refreshed
+// periodically, along with the outcome of their last refresh
+func QueryEnabledRepos(repos *[]common.RefreshStatus) error {
+	return store.QueryEnabledRepos(repos)
+}
+
+// SetRefreshSuccess records the time of the last successful refresh
+// of a repository
+func SetRefreshSuccess(owner, name string, at time.Time) error {
+	return store.SetRefreshSuccess(owner, name, at)
+}
 
-	// assign to data
-	data.Repository = *repo
+// SetRefreshFailure records the time and the cause of the last failed
+// refresh of a repository
+func SetRefreshFailure(owner, name string, at time.Time, cause error) error {
+	return store.SetRefreshFailure(owner, name, at, cause)
+}
+
+// LatestStarredAt returns when the most recent stargazer stored for the
+// repository starred it, or a zero time.Time if none is stored
+func LatestStarredAt(owner, name string) (time.Time, error) {
+	return store.LatestStarredAt(owner, name)
+}
+
+// AddStargazers stores the stargazers of a repository, skipping the
+// ones already stored
+func AddStargazers(owner, name string, stargazers []common.Stargazer) error {
+	return store.AddStargazers(owner, name, stargazers)
+}
+
+// QueryStarDates fetches the date of every star the repository received,
+// including the ones of users who unstarred it later, oldest first
+func QueryStarDates(owner, name string, dates *[]time.Time) error {
+	return store.QueryStarDates(owner, name, dates)
+}
+
+// QueryStargazers fetches the stored stargazers of a repository, oldest
+// first
+func QueryStargazers(owner, name string, stargazers *[]common.Stargazer) error {
+	return store.QueryStargazers(owner, name, stargazers)
+}
+
+// AddUnstars records that the users unstarred the repository, moving
+// them out of its stargazers
+func AddUnstars(owner, name string, unstars []common.Unstar) error {
+	return store.AddUnstars(owner, name, unstars)
+}
+
+// UnstarsCheckedAt returns when the stargazers of the repository were
+// last compared with the ones GitHub lists, or a zero time.Time if they
+// never were
+func UnstarsCheckedAt(owner, name string) (time.Time, error) {
+	return store.UnstarsCheckedAt(owner, name)
+}
+
+// SetUnstarsChecked records when the stargazers of the repository were
+// compared with the ones GitHub lists
+func SetUnstarsChecked(owner, name string, at time.Time) error {
+	return store.SetUnstarsChecked(owner, name, at)
+}
+
+// QueryUnstars fetches the unstars recorded for a repository, oldest
+// first
+func QueryUnstars(owner, name string, unstars *[]common.Unstar) error {
+	return store.QueryUnstars(owner, name, unstars)
+}
+
+// ReplaceStarBuckets stores the star buckets of a repository for a
+// granularity, replacing the ones stored before
+func ReplaceStarBuckets(owner, name, granularity string, buckets []common.StarBucket) error {
+	return store.ReplaceStarBuckets(owner, name, granularity, buckets)
+}
+
+// QueryStarBuckets fetches the star buckets of a repository for a
+// granularity, oldest first
+func QueryStarBuckets(owner, name, granularity string, buckets *[]common.StarBucket) error {
+	return store.QueryStarBuckets(owner, name, granularity, buckets)
+}
+
+// ReplaceSpikeEvents replaces the spikes stored for a repository
+func ReplaceSpikeEvents(owner, name string, events []common.SpikeEvent) error {
+	return store.ReplaceSpikeEvents(owner, name, events)
+}
+
+// QuerySpikeEvents fetches the spikes of a repository, oldest first
+func QuerySpikeEvents(owner, name string, events *[]common.SpikeEvent) error {
+	return store.QuerySpikeEvents(owner, name, events)
+}
+
+// LatestForkCreatedAt returns when the most recent fork stored for the
+// repository was created, or a zero time.Time if none is stored
+func LatestForkCreatedAt(owner, name string) (time.Time, error) {
+	return store.LatestForkCreatedAt(owner, name)
+}
+
+// AddForks stores the forks of a repository, skipping the ones already
+// stored
+func AddForks(owner, name string, forks []common.Fork) error {
+	return store.AddForks(owner, name, forks)
+}
+
+// QueryForkDates fetches when each stored fork of the repository was
+// created, oldest first
+func QueryForkDates(owner, name string, dates *[]time.Time) error {
+	return store.QueryForkDates(owner, name, dates)
+}
+
+// QueryCommitSHAs fetches the SHAs of the commits stored for the
+// repository
+func QueryCommitSHAs(owner, name string, shas *[]string) error {
+	return store.QueryCommitSHAs(owner, name, shas)
+}
+
+// AddCommits stores the commits of a repository, skipping the ones
+// already stored
+func AddCommits(owner, name string, commits []common.Commit) error {
+	return store.AddCommits(owner, name, commits)
+}
+
+// QueryCommitDates fetches when each stored commit of the repository was
+// committed, oldest first
+func QueryCommitDates(owner, name string, dates *[]time.Time) error {
+	return store.QueryCommitDates(owner, name, dates)
+}
+
+// AddContributors stores the contributors of a repository, updating the
+// contributions of the ones already stored
+func AddContributors(owner, name string, contributors []common.Contributor) error {
+	return store.AddContributors(owner, name, contributors)
+}
+
+// AddContributorWeeks stores the weekly commits of the contributors of a
+// repository, updating the weeks already stored
+func AddContributorWeeks(owner, name string, weeks []common.ContributorWeek) error {
+	return store.AddContributorWeeks(owner, name, weeks)
+}
+
+// CountContributors returns how many contributors are stored for the
+// repository
+func CountContributors(owner, name string) (int, error) {
+	return store.CountContributors(owner, name)
+}
+
+// QueryContributorWeeks fetches the weeks the contributors of the
+// repository authored commits in, oldest first
+func QueryContributorWeeks(owner, name string, weeks *[]common.ContributorWeek) error {
+	return store.QueryContributorWeeks(owner, name, weeks)
+}
+
+// AddReleases stores the releases and the tags of a repository, updating
+// the releases already stored
+func AddReleases(owner, name string, releases []common.Release) error {
+	return store.AddReleases(owner, name, releases)
+}
+
+// QueryReleases fetches the stored releases of the repository, oldest
+// first, followed by the tags no release was published for
+func QueryReleases(owner, name string, releases *[]common.Release) error {
+	return store.QueryReleases(owner, name, releases)
+}
+
+// IssuesSyncedAt returns when the issues of the repository were last
+// synced, or a zero time.Time if they never were
+func IssuesSyncedAt(owner, name string) (time.Time, error) {
+	return store.IssuesSyncedAt(owner, name)
+}
+
+// SetIssuesSynced records when the issues of the repository were synced
+func SetIssuesSynced(owner, name string, at time.Time) error {
+	return store.SetIssuesSynced(owner, name, at)
+}
+
+// AddIssues stores the issues and pull requests of a repository,
+// updating the ones already stored
+func AddIssues(owner, name string, issues []common.Issue) error {
+	return store.AddIssues(owner, name, issues)
+}
+
+// AddPullRequestMerges records when the pull requests, keyed by number,
+// were merged
+func AddPullRequestMerges(owner, name string, merges map[int]time.Time) error {
+	return store.AddPullRequestMerges(owner, name, merges)
+}
+
+// AddIssueComments records the first response to the issues and pull
+// requests, ignoring the comments of their authors
+func AddIssueComments(owner, name string, comments []common.IssueComment) error {
+	return store.AddIssueComments(owner, name, comments)
+}
 
-	return nil
+// QueryIssues fetches the stored issues and pull requests of a
+// repository, oldest first
+func QueryIssues(owner, name string, issues *[]common.Issue) error {
+	return store.QueryIssues(owner, name, issues)
 }
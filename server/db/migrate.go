@@ -0,0 +1,207 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles contains the schema migrations, named
+// `NNNN_description.up.sql` and `NNNN_description.down.sql`.
+// `{{serial}}` is replaced with the auto-incrementing integer type of
+// the dialect
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration describes a schema migration and whether it was applied
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	up        string
+	down      string
+}
+
+// loadMigrations reads the embedded migrations, sorted by version
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		filename := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(filename, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(filename, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("Bad migration file name %s", filename)
+		}
+		base := strings.TrimSuffix(filename, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("Bad migration file name %s", filename)
+		}
+		content, err := migrationFiles.ReadFile(path.Join("migrations", filename))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("Migration %04d is missing its up or down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// expand adapts the migration to the store dialect
+func (s *sqlStore) expand(statement string) string {
+	serial := "serial"
+	if s.dialect == sqliteDialect {
+		serial = "integer"
+	}
+	return strings.Replace(statement, "{{serial}}", serial, -1)
+}
+
+func (s *sqlStore) createMigrationsTable() error {
+	_, err := s.exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version integer PRIMARY KEY,
+			name varchar(191) NOT NULL,
+			applied_at timestamp NOT NULL
+		)`)
+	return err
+}
+
+// Migrations lists the known migrations, with the time each one was
+// applied at if it was
+func (s *sqlStore) Migrations() ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	err = s.createMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		if appliedAt, ok := applied[migrations[i].Version]; ok {
+			migrations[i].AppliedAt = &appliedAt
+		}
+	}
+	return migrations, nil
+}
+
+// MigrateUp applies every migration not applied yet, in order
+func (s *sqlStore) MigrateUp() error {
+	migrations, err := s.Migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			continue
+		}
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(s.expand(m.up))
+		if err == nil {
+			_, err = tx.Exec(s.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)"),
+				m.Version, m.Name, time.Now().UTC())
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Migration %04d_%s failed: %v", m.Version, m.Name, err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied migration %04d_%s\n", m.Version, m.Name)
+	}
+	return nil
+}
+
+// MigrateDown reverts the last `steps` applied migrations
+func (s *sqlStore) MigrateDown(steps int) error {
+	migrations, err := s.Migrations()
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if m.AppliedAt == nil {
+			continue
+		}
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(s.expand(m.down))
+		if err == nil {
+			_, err = tx.Exec(s.rebind("DELETE FROM schema_migrations WHERE version = $1"), m.Version)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Reverting migration %04d_%s failed: %v", m.Version, m.Name, err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted migration %04d_%s\n", m.Version, m.Name)
+		steps--
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS repositories (
+    id {{serial}} PRIMARY KEY,
+    id_of_repository_on_github integer,
+    repository_name varchar(191),
+    repository_owner varchar(191),
+    default_branch varchar(100),
+    created_at varchar(20),
+    added_at timestamp,
+    enabled boolean DEFAULT true NOT NULL,
+    private boolean DEFAULT false NOT NULL,
+    fork boolean DEFAULT false NOT NULL,
+    description text DEFAULT '',
+    initialized boolean DEFAULT false NOT NULL,
+    has_static_public_page boolean DEFAULT false NOT NULL,
+    repository_created_months_ago text,
+    total_stars integer DEFAULT 0,
+    total_issues_opened integer DEFAULT 0,
+    total_commits integer DEFAULT 0,
+    commits_count_last_12_months integer DEFAULT 0,
+    commits_count_last_4_weeks integer DEFAULT 0,
+    commits_count_last_week integer DEFAULT 0,
+    stars_count_last_12_months integer DEFAULT 0,
+    stars_count_last_4_weeks integer DEFAULT 0,
+    stars_count_last_week integer DEFAULT 0,
+    stars_per_month text,
+    CONSTRAINT repositories_repository_id_unique UNIQUE (id_of_repository_on_github)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS repository_snapshots (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    recorded_at timestamp NOT NULL,
+    total_stars integer DEFAULT 0,
+    total_commits integer DEFAULT 0,
+    commits_count_last_12_months integer DEFAULT 0,
+    commits_count_last_4_weeks integer DEFAULT 0,
+    commits_count_last_week integer DEFAULT 0,
+    stars_count_last_12_months integer DEFAULT 0,
+    stars_count_last_4_weeks integer DEFAULT 0,
+    stars_count_last_week integer DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS repository_snapshots_repository_id_recorded_at_idx
+    ON repository_snapshots (repository_id, recorded_at);
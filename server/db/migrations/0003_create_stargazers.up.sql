@@ -0,0 +1,10 @@
+CREATE TABLE stargazers (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    user_login varchar(191) NOT NULL,
+    starred_at timestamp NOT NULL,
+    CONSTRAINT stargazers_repository_id_user_login_unique UNIQUE (repository_id, user_login)
+);
+
+CREATE INDEX stargazers_repository_id_starred_at_idx
+    ON stargazers (repository_id, starred_at);
@@ -0,0 +1,5 @@
+ALTER TABLE repositories DROP COLUMN unstars_count_last_week;
+ALTER TABLE repositories DROP COLUMN unstars_count_last_4_weeks;
+ALTER TABLE repositories DROP COLUMN unstars_count_last_12_months;
+
+DROP TABLE unstars;
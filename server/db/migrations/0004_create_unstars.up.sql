@@ -0,0 +1,14 @@
+CREATE TABLE unstars (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    user_login varchar(191) NOT NULL,
+    starred_at timestamp NOT NULL,
+    unstarred_at timestamp NOT NULL
+);
+
+CREATE INDEX unstars_repository_id_unstarred_at_idx
+    ON unstars (repository_id, unstarred_at);
+
+ALTER TABLE repositories ADD COLUMN unstars_count_last_12_months integer DEFAULT 0;
+ALTER TABLE repositories ADD COLUMN unstars_count_last_4_weeks integer DEFAULT 0;
+ALTER TABLE repositories ADD COLUMN unstars_count_last_week integer DEFAULT 0;
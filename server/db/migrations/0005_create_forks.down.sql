@@ -0,0 +1,7 @@
+ALTER TABLE repositories DROP COLUMN forks_per_month;
+ALTER TABLE repositories DROP COLUMN forks_count_last_week;
+ALTER TABLE repositories DROP COLUMN forks_count_last_4_weeks;
+ALTER TABLE repositories DROP COLUMN forks_count_last_12_months;
+ALTER TABLE repositories DROP COLUMN total_forks;
+
+DROP TABLE forks;
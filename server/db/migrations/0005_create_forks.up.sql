@@ -0,0 +1,17 @@
+CREATE TABLE forks (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    id_of_fork_on_github bigint NOT NULL,
+    full_name varchar(191) NOT NULL,
+    created_at timestamp NOT NULL,
+    CONSTRAINT forks_repository_id_fork_id_unique UNIQUE (repository_id, id_of_fork_on_github)
+);
+
+CREATE INDEX forks_repository_id_created_at_idx
+    ON forks (repository_id, created_at);
+
+ALTER TABLE repositories ADD COLUMN total_forks integer DEFAULT 0;
+ALTER TABLE repositories ADD COLUMN forks_count_last_12_months integer DEFAULT 0;
+ALTER TABLE repositories ADD COLUMN forks_count_last_4_weeks integer DEFAULT 0;
+ALTER TABLE repositories ADD COLUMN forks_count_last_week integer DEFAULT 0;
+ALTER TABLE repositories ADD COLUMN forks_per_month text;
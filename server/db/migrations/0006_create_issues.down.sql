@@ -0,0 +1,3 @@
+ALTER TABLE repositories DROP COLUMN issues_synced_at;
+
+DROP TABLE issues;
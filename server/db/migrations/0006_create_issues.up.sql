@@ -0,0 +1,21 @@
+CREATE TABLE issues (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    number integer NOT NULL,
+    is_pull_request boolean DEFAULT false NOT NULL,
+    title text DEFAULT '',
+    author varchar(191) DEFAULT '',
+    state varchar(20) NOT NULL,
+    labels text DEFAULT '[]',
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    closed_at timestamp,
+    merged_at timestamp,
+    first_response_at timestamp,
+    CONSTRAINT issues_repository_id_number_unique UNIQUE (repository_id, number)
+);
+
+CREATE INDEX issues_repository_id_created_at_idx
+    ON issues (repository_id, created_at);
+
+ALTER TABLE repositories ADD COLUMN issues_synced_at timestamp;
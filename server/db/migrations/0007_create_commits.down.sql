@@ -0,0 +1,3 @@
+ALTER TABLE repositories DROP COLUMN commits_per_month;
+
+DROP TABLE commits;
@@ -0,0 +1,13 @@
+CREATE TABLE commits (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    sha varchar(40) NOT NULL,
+    author varchar(191) DEFAULT '',
+    committed_at timestamp NOT NULL,
+    CONSTRAINT commits_repository_id_sha_unique UNIQUE (repository_id, sha)
+);
+
+CREATE INDEX commits_repository_id_committed_at_idx
+    ON commits (repository_id, committed_at);
+
+ALTER TABLE repositories ADD COLUMN commits_per_month text;
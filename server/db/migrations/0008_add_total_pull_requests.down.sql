@@ -0,0 +1 @@
+ALTER TABLE repositories DROP COLUMN total_pull_requests;
@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN total_pull_requests integer DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP TABLE contributor_weeks;
+DROP TABLE contributors;
@@ -0,0 +1,16 @@
+CREATE TABLE contributors (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    login varchar(191) NOT NULL,
+    contributions integer DEFAULT 0 NOT NULL,
+    CONSTRAINT contributors_repository_id_login_unique UNIQUE (repository_id, login)
+);
+
+CREATE TABLE contributor_weeks (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    login varchar(191) NOT NULL,
+    week timestamp NOT NULL,
+    commits integer DEFAULT 0 NOT NULL,
+    CONSTRAINT contributor_weeks_repository_id_login_week_unique UNIQUE (repository_id, login, week)
+);
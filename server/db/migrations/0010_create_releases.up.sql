@@ -0,0 +1,10 @@
+CREATE TABLE releases (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    tag varchar(191) NOT NULL,
+    name text DEFAULT '',
+    published_at timestamp,
+    prerelease boolean DEFAULT false NOT NULL,
+    downloads integer DEFAULT 0 NOT NULL,
+    CONSTRAINT releases_repository_id_tag_unique UNIQUE (repository_id, tag)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE star_buckets (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    granularity varchar(10) NOT NULL,
+    period varchar(10) NOT NULL,
+    period_start timestamp NOT NULL,
+    count integer DEFAULT 0 NOT NULL,
+    cumulative integer DEFAULT 0 NOT NULL,
+    CONSTRAINT star_buckets_repository_id_granularity_period_unique UNIQUE (repository_id, granularity, period_start)
+);
@@ -0,0 +1 @@
+DROP TABLE spike_events;
@@ -0,0 +1,10 @@
+CREATE TABLE spike_events (
+    id {{serial}} PRIMARY KEY,
+    repository_id integer NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    metric varchar(10) NOT NULL,
+    day timestamp NOT NULL,
+    count integer DEFAULT 0 NOT NULL,
+    baseline double precision DEFAULT 0 NOT NULL,
+    magnitude double precision DEFAULT 0 NOT NULL,
+    CONSTRAINT spike_events_repository_id_metric_day_unique UNIQUE (repository_id, metric, day)
+);
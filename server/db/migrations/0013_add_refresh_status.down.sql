@@ -0,0 +1,3 @@
+ALTER TABLE repositories DROP COLUMN last_refresh_error;
+ALTER TABLE repositories DROP COLUMN last_refresh_failure_at;
+ALTER TABLE repositories DROP COLUMN last_refresh_success_at;
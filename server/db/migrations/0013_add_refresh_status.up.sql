@@ -0,0 +1,3 @@
+ALTER TABLE repositories ADD COLUMN last_refresh_success_at timestamp;
+ALTER TABLE repositories ADD COLUMN last_refresh_failure_at timestamp;
+ALTER TABLE repositories ADD COLUMN last_refresh_error text;
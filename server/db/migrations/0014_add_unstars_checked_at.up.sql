@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN unstars_checked_at timestamp;
@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN stars_per_month text;
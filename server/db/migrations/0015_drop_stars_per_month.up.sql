@@ -0,0 +1 @@
+ALTER TABLE repositories DROP COLUMN stars_per_month;
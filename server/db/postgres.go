@@ -0,0 +1,53 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	// Postgres drivers
+	_ "github.com/lib/pq"
+)
+
+const (
+	dbhost = "DBHOST"
+	dbport = "DBPORT"
+	dbuser = "DBUSER"
+	dbpass = "DBPASS"
+	dbname = "DBNAME"
+)
+
+// newPostgresStore connects to the Postgres database configured through
+// the DBHOST, DBPORT, DBUSER, DBPASS and DBNAME environment variables
+func newPostgresStore() (Store, error) {
+	config, err := dbConfig()
+	if err != nil {
+		return nil, err
+	}
+	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
+		"password='%s' dbname=%s sslmode=disable",
+		config[dbhost], config[dbport],
+		config[dbuser], config[dbpass], config[dbname])
+
+	db, err := sql.Open("postgres", psqlInfo)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: postgresDialect}, nil
+}
+
+func dbConfig() (map[string]string, error) {
+	conf := make(map[string]string)
+	for _, key := range []string{dbhost, dbport, dbuser, dbpass, dbname} {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return nil, fmt.Errorf("%s environment variable required but not set", key)
+		}
+		conf[key] = value
+	}
+	return conf, nil
+}
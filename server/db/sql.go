@@ -0,0 +1,1596 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// sqlStore implements Store on top of database/sql. Queries are written
+// with Postgres `$N` placeholders and rewritten for the other dialects
+type sqlStore struct {
+	db      *sql.DB
+	dialect string
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// rebind rewrites the `$N` placeholders of the query in the syntax of
+// the store dialect
+func (s *sqlStore) rebind(query string) string {
+	if s.dialect == sqliteDialect {
+		return placeholder.ReplaceAllString(query, "?$1")
+	}
+	return query
+}
+
+func (s *sqlStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(s.rebind(query), args...)
+}
+
+func (s *sqlStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRow(s.rebind(query), args...)
+}
+
+func (s *sqlStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.rebind(query), args...)
+}
+
+// repositoryID returns the id of the row of the repository in the
+// repositories table
+func (s *sqlStore) repositoryID(owner, name string) (int, error) {
+	var id int
+	err := s.queryRow("SELECT id FROM repositories WHERE repository_owner=$1 AND repository_name=$2", owner, name).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, common.ErrRepoNotFound("Repository not found")
+	case err != nil:
+		return 0, err
+	}
+	return id, nil
+}
+
+// Close closes the connection to the database
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// sortColumns maps the sort keys of the index to the columns ordering
+// it. Ids grow with insertion, so they order repositories by addition
+var sortColumns = map[string]string{
+	"stars":              "total_stars",
+	"commits":            "total_commits",
+	"stars_last_week":    "stars_count_last_week",
+	"stars_last_4_weeks": "stars_count_last_4_weeks",
+	"name":               "repository_name",
+	"added_at":           "id",
+}
+
+// ValidSort reports whether the index can be sorted by `key`
+func ValidSort(key string) bool {
+	_, ok := sortColumns[key]
+	return ok
+}
+
+// QueryRepos fetches a page of repositories, ordered by the sort column
+// then by id, so a cursor made of the values of the last row of a page
+// marks where the next one starts
+func (s *sqlStore) QueryRepos(opt common.RepoListOptions, repos *common.Repositories) error {
+	column, ok := sortColumns[opt.Sort]
+	if !ok {
+		return common.ErrBadRequest("Unknown sort " + opt.Sort)
+	}
+	direction, comparison := "DESC", "<"
+	if opt.Order == "asc" {
+		direction, comparison = "ASC", ">"
+	}
+
+	sqlStatement := `
+		SELECT
+			id,
+			repository_owner,
+			repository_name,
+			COALESCE(description, ''),
+			total_stars,
+			total_commits,
+			total_forks,
+			stars_count_last_week,
+			stars_count_last_4_weeks,
+			added_at
+		FROM repositories
+		WHERE 1 = 1`
+	var args []interface{}
+	if opt.Owner != "" {
+		args = append(args, opt.Owner)
+		sqlStatement += fmt.Sprintf(" AND LOWER(repository_owner) = LOWER($%d)", len(args))
+	}
+	if opt.Query != "" {
+		args = append(args, "%"+escapeLike(strings.ToLower(opt.Query))+"%")
+		sqlStatement += fmt.Sprintf(` AND (LOWER(repository_name) LIKE $%[1]d ESCAPE '\' OR LOWER(description) LIKE $%[1]d ESCAPE '\')`, len(args))
+	}
+	if opt.Cursor != "" {
+		value, id, err := decodeCursor(opt.Cursor, column)
+		if err != nil {
+			return err
+		}
+		args = append(args, value, id)
+		sqlStatement += fmt.Sprintf(" AND (%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id %[2]s $%[4]d))",
+			column, comparison, len(args)-1, len(args))
+	}
+	// one more row than asked tells whether there is a next page
+	args = append(args, opt.Limit+1)
+	sqlStatement += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT $%[3]d", column, direction, len(args))
+
+	rows, err := s.query(sqlStatement, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	repos.Repositories = []common.RepositorySummary{}
+	for rows.Next() {
+		repo := common.RepositorySummary{}
+		err = rows.Scan(
+			&repo.ID,
+			&repo.OwnerName,
+			&repo.Name,
+			&repo.Description,
+			&repo.TotalStars,
+			&repo.TotalCommits,
+			&repo.TotalForks,
+			&repo.StarsCountLastWeek,
+			&repo.StarsCountLast4Weeks,
+			&repo.AddedAt,
+		)
+		if err != nil {
+			return err
+		}
+		repos.Repositories = append(repos.Repositories, repo)
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	if len(repos.Repositories) > opt.Limit {
+		repos.Repositories = repos.Repositories[:opt.Limit]
+		repos.NextCursor = encodeCursor(repos.Repositories[opt.Limit-1], column)
+	}
+	return nil
+}
+
+// encodeCursor returns the cursor of the page following `last`
+func encodeCursor(last common.RepositorySummary, column string) string {
+	var value interface{}
+	switch column {
+	case "total_stars":
+		value = last.TotalStars
+	case "total_commits":
+		value = last.TotalCommits
+	case "stars_count_last_week":
+		value = last.StarsCountLastWeek
+	case "stars_count_last_4_weeks":
+		value = last.StarsCountLast4Weeks
+	case "repository_name":
+		value = last.Name
+	default:
+		value = last.ID
+	}
+	out, _ := json.Marshal([]interface{}{value, last.ID})
+	return base64.RawURLEncoding.EncodeToString(out)
+}
+
+// decodeCursor returns the sort column value and the id a cursor holds
+func decodeCursor(cursor, column string) (interface{}, int64, error) {
+	bad := common.ErrBadRequest("Invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, bad
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var values []interface{}
+	if decoder.Decode(&values) != nil || len(values) != 2 {
+		return nil, 0, bad
+	}
+	idNumber, ok := values[1].(json.Number)
+	if !ok {
+		return nil, 0, bad
+	}
+	id, err := idNumber.Int64()
+	if err != nil {
+		return nil, 0, bad
+	}
+	if column == "repository_name" {
+		name, ok := values[0].(string)
+		if !ok {
+			return nil, 0, bad
+		}
+		return name, id, nil
+	}
+	number, ok := values[0].(json.Number)
+	if !ok {
+		return nil, 0, bad
+	}
+	value, err := number.Int64()
+	if err != nil {
+		return nil, 0, bad
+	}
+	return value, id, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(pattern string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(pattern)
+}
+
+// QueryOwnerRepos fetches the counters of the repositories of an owner,
+// whatever the case the owner is spelled with
+func (s *sqlStore) QueryOwnerRepos(owner string, repos *[]common.OwnerRepository) error {
+	rows, err := s.query(`
+		SELECT
+			repository_name,
+			total_stars,
+			total_commits,
+			total_forks,
+			stars_count_last_4_weeks,
+			commits_count_last_4_weeks,
+			commits_count_last_12_months
+		FROM repositories
+		WHERE LOWER(repository_owner) = LOWER($1)
+		ORDER BY repository_name`, owner)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		repo := common.OwnerRepository{}
+		err = rows.Scan(
+			&repo.Name,
+			&repo.TotalStars,
+			&repo.TotalCommits,
+			&repo.TotalForks,
+			&repo.StarsCountLast4Weeks,
+			&repo.CommitsCountLast4Weeks,
+			&repo.CommitsCountLast12Months,
+		)
+		if err != nil {
+			return err
+		}
+		*repos = append(*repos, repo)
+	}
+	return rows.Err()
+}
+
+// QueryOwnerStarDates fetches when each star of the repositories of an
+// owner was given, including the stars later removed
+func (s *sqlStore) QueryOwnerStarDates(owner string, dates *[]time.Time) error {
+	return s.queryDates(dates, `
+		SELECT stargazers.starred_at
+		FROM stargazers
+		JOIN repositories ON repositories.id = stargazers.repository_id
+		WHERE LOWER(repositories.repository_owner) = LOWER($1)
+		UNION ALL
+		SELECT unstars.starred_at
+		FROM unstars
+		JOIN repositories ON repositories.id = unstars.repository_id
+		WHERE LOWER(repositories.repository_owner) = LOWER($1)`, owner)
+}
+
+// QueryOwnerCommitDates fetches when each commit of the repositories of
+// an owner was committed
+func (s *sqlStore) QueryOwnerCommitDates(owner string, dates *[]time.Time) error {
+	return s.queryDates(dates, `
+		SELECT commits.committed_at
+		FROM commits
+		JOIN repositories ON repositories.id = commits.repository_id
+		WHERE LOWER(repositories.repository_owner) = LOWER($1)`, owner)
+}
+
+// queryDates appends the timestamps selected by the query to `dates`
+func (s *sqlStore) queryDates(dates *[]time.Time, query string, args ...interface{}) error {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date time.Time
+		err = rows.Scan(&date)
+		if err != nil {
+			return err
+		}
+		*dates = append(*dates, date)
+	}
+	return rows.Err()
+}
+
+// AddNewRepo adds a repository to the db
+func (s *sqlStore) AddNewRepo(owner, name string, repo *common.Repository) error {
+	var id int
+
+	err := s.queryRow("SELECT id_of_repository_on_github FROM repositories WHERE repository_owner=$1 AND repository_name=$2", owner, name).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		// Repo is new
+
+		sqlStatement := `
+			INSERT INTO repositories (
+				id_of_repository_on_github,
+				repository_name,
+				repository_owner,
+				default_branch,
+				created_at,
+				description,
+				repository_created_months_ago,
+				total_stars,
+				total_commits,
+				commits_count_last_12_months,
+				commits_count_last_4_weeks,
+				commits_count_last_week,
+				stars_count_last_12_months,
+				stars_count_last_4_weeks,
+				stars_count_last_week,
+				unstars_count_last_12_months,
+				unstars_count_last_4_weeks,
+				unstars_count_last_week,
+				fork,
+				total_forks,
+				forks_count_last_12_months,
+				forks_count_last_4_weeks,
+				forks_count_last_week,
+				forks_per_month,
+				commits_per_month,
+				total_issues_opened,
+				total_pull_requests,
+				added_at
+				)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)`
+		_, err := s.exec(
+			sqlStatement,
+			repo.ID,
+			repo.Name,
+			repo.OwnerName,
+			repo.DefaultBranch,
+			repo.CreatedAt,
+			repo.Description,
+			repo.RepoAge,
+			repo.TotalStars,
+			repo.TotalCommits,
+			repo.CommitsCountLast12Months,
+			repo.CommitsCountLast4Weeks,
+			repo.CommitsCountLastWeek,
+			repo.StarsCountLast12Months,
+			repo.StarsCountLast4Weeks,
+			repo.StarsCountLastWeek,
+			repo.UnstarsCountLast12Months,
+			repo.UnstarsCountLast4Weeks,
+			repo.UnstarsCountLastWeek,
+			repo.Fork,
+			repo.TotalForks,
+			repo.ForksCountLast12Months,
+			repo.ForksCountLast4Weeks,
+			repo.ForksCountLastWeek,
+			repo.ForksPerMonth,
+			repo.CommitsPerMonth,
+			repo.TotalIssuesOpened,
+			repo.TotalPullRequests,
+			time.Now().UTC(),
+		)
+
+		if err != nil {
+			return err
+		}
+
+	case err != nil:
+		return err
+	default:
+		return s.UpdateRepo(owner, name, repo)
+	}
+
+	return nil
+}
+
+// UpdateRepo updates the data of a repository already in the db
+func (s *sqlStore) UpdateRepo(owner, name string, repo *common.Repository) error {
+	sqlStatement := `
+		UPDATE repositories SET
+			default_branch = $1,
+			description = $2,
+			repository_created_months_ago = $3,
+			total_stars = $4,
+			total_commits = $5,
+			commits_count_last_12_months = $6,
+			commits_count_last_4_weeks = $7,
+			commits_count_last_week = $8,
+			stars_count_last_12_months = $9,
+			stars_count_last_4_weeks = $10,
+			stars_count_last_week = $11,
+			unstars_count_last_12_months = $12,
+			unstars_count_last_4_weeks = $13,
+			unstars_count_last_week = $14,
+			fork = $15,
+			total_forks = $16,
+			forks_count_last_12_months = $17,
+			forks_count_last_4_weeks = $18,
+			forks_count_last_week = $19,
+			forks_per_month = $20,
+			commits_per_month = $21,
+			total_issues_opened = $22,
+			total_pull_requests = $23
+		WHERE repository_owner = $24 AND repository_name = $25`
+	res, err := s.exec(
+		sqlStatement,
+		repo.DefaultBranch,
+		repo.Description,
+		repo.RepoAge,
+		repo.TotalStars,
+		repo.TotalCommits,
+		repo.CommitsCountLast12Months,
+		repo.CommitsCountLast4Weeks,
+		repo.CommitsCountLastWeek,
+		repo.StarsCountLast12Months,
+		repo.StarsCountLast4Weeks,
+		repo.StarsCountLastWeek,
+		repo.UnstarsCountLast12Months,
+		repo.UnstarsCountLast4Weeks,
+		repo.UnstarsCountLastWeek,
+		repo.Fork,
+		repo.TotalForks,
+		repo.ForksCountLast12Months,
+		repo.ForksCountLast4Weeks,
+		repo.ForksCountLastWeek,
+		repo.ForksPerMonth,
+		repo.CommitsPerMonth,
+		repo.TotalIssuesOpened,
+		repo.TotalPullRequests,
+		owner,
+		name,
+	)
+
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// FetchRepo given a Repository value with name and owner of the repo
+// fetches more details from the database and fills the value with more
+// data
+func (s *sqlStore) FetchRepo(repo *common.Repository, data *common.RepoData) error {
+	if len(repo.Name) == 0 {
+		return fmt.Errorf("Repository name not correctly set")
+	}
+	if len(repo.OwnerName) == 0 {
+		return fmt.Errorf("Repository owner not correctly set")
+	}
+	sqlStatement := `
+		SELECT
+			id,
+			initialized,
+			repository_created_months_ago,
+			total_stars,
+			total_commits,
+			description,
+			commits_count_last_12_months,
+			commits_count_last_4_weeks,
+			commits_count_last_week,
+			stars_count_last_12_months,
+			stars_count_last_4_weeks,
+			stars_count_last_week,
+			unstars_count_last_12_months,
+			unstars_count_last_4_weeks,
+			unstars_count_last_week,
+			fork,
+			total_forks,
+			forks_count_last_12_months,
+			forks_count_last_4_weeks,
+			forks_count_last_week,
+			COALESCE(forks_per_month, ''),
+			COALESCE(commits_per_month, ''),
+			total_issues_opened,
+			total_pull_requests
+		FROM repositories
+		WHERE repository_owner=$1 and repository_name=$2
+		LIMIT 1;`
+	row := s.queryRow(sqlStatement, repo.OwnerName, repo.Name)
+	err := row.Scan(
+		&repo.ID,
+		&repo.Initialized,
+		&repo.RepoAge,
+		&repo.TotalStars,
+		&repo.TotalCommits,
+		&repo.Description,
+		&repo.CommitsCountLast12Months,
+		&repo.CommitsCountLast4Weeks,
+		&repo.CommitsCountLastWeek,
+		&repo.StarsCountLast12Months,
+		&repo.StarsCountLast4Weeks,
+		&repo.StarsCountLastWeek,
+		&repo.UnstarsCountLast12Months,
+		&repo.UnstarsCountLast4Weeks,
+		&repo.UnstarsCountLastWeek,
+		&repo.Fork,
+		&repo.TotalForks,
+		&repo.ForksCountLast12Months,
+		&repo.ForksCountLast4Weeks,
+		&repo.ForksCountLastWeek,
+		&repo.ForksPerMonth,
+		&repo.CommitsPerMonth,
+		&repo.TotalIssuesOpened,
+		&repo.TotalPullRequests)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			//locally handle SQL error, abstract for caller
+			return common.ErrRepoNotFound("Repository not found")
+		default:
+			return err
+		}
+	}
+	if !repo.Initialized {
+		return common.ErrRepoNotInitialized("Repository not initialized")
+	}
+	if repo.RepoAge < 3 {
+		return common.ErrRepoNotInitialized("Repository not initialized")
+	}
+	repo.NetStarsCountLast12Months = repo.StarsCountLast12Months - repo.UnstarsCountLast12Months
+	repo.NetStarsCountLast4Weeks = repo.StarsCountLast4Weeks - repo.UnstarsCountLast4Weeks
+	repo.NetStarsCountLastWeek = repo.StarsCountLastWeek - repo.UnstarsCountLastWeek
+
+	// assign to data
+	data.Repository = *repo
+
+	return nil
+}
+
+// AddRepoSnapshot appends a timestamped snapshot of the repository
+// counters to the history of the repository
+func (s *sqlStore) AddRepoSnapshot(owner, name string, repo *common.Repository) error {
+	sqlStatement := `
+		INSERT INTO repository_snapshots (
+			repository_id,
+			recorded_at,
+			total_stars,
+			total_commits,
+			commits_count_last_12_months,
+			commits_count_last_4_weeks,
+			commits_count_last_week,
+			stars_count_last_12_months,
+			stars_count_last_4_weeks,
+			stars_count_last_week
+			)
+		SELECT id, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		FROM repositories
+		WHERE repository_owner=$1 AND repository_name=$2`
+	res, err := s.exec(
+		sqlStatement,
+		owner,
+		name,
+		time.Now().UTC(),
+		repo.TotalStars,
+		repo.TotalCommits,
+		repo.CommitsCountLast12Months,
+		repo.CommitsCountLast4Weeks,
+		repo.CommitsCountLastWeek,
+		repo.StarsCountLast12Months,
+		repo.StarsCountLast4Weeks,
+		repo.StarsCountLastWeek,
+	)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return common.ErrRepoNotFound("Repository not found")
+	}
+	return nil
+}
+
+// QueryRepoHistory fetches the snapshots of a repository recorded
+// between `from` and `to`. A zero time.Time leaves that end of the
+// range open
+func (s *sqlStore) QueryRepoHistory(owner, name string, from, to time.Time, history *common.RepositoryHistory) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	sqlStatement := `
+		SELECT
+			recorded_at,
+			total_stars,
+			total_commits,
+			commits_count_last_12_months,
+			commits_count_last_4_weeks,
+			commits_count_last_week,
+			stars_count_last_12_months,
+			stars_count_last_4_weeks,
+			stars_count_last_week
+		FROM repository_snapshots
+		WHERE repository_id=$1`
+	args := []interface{}{id}
+	if !from.IsZero() {
+		args = append(args, from.UTC())
+		sqlStatement += fmt.Sprintf(" AND recorded_at >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to.UTC())
+		sqlStatement += fmt.Sprintf(" AND recorded_at <= $%d", len(args))
+	}
+	sqlStatement += " ORDER BY recorded_at ASC"
+
+	rows, err := s.query(sqlStatement, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	history.OwnerName = owner
+	history.Name = name
+	history.Snapshots = []common.RepositorySnapshot{}
+	for rows.Next() {
+		snapshot := common.RepositorySnapshot{}
+		err = rows.Scan(
+			&snapshot.RecordedAt,
+			&snapshot.TotalStars,
+			&snapshot.TotalCommits,
+			&snapshot.CommitsCountLast12Months,
+			&snapshot.CommitsCountLast4Weeks,
+			&snapshot.CommitsCountLastWeek,
+			&snapshot.StarsCountLast12Months,
+			&snapshot.StarsCountLast4Weeks,
+			&snapshot.StarsCountLastWeek,
+		)
+		if err != nil {
+			return err
+		}
+		history.Snapshots = append(history.Snapshots, snapshot)
+	}
+	return rows.Err()
+}
+
+// CountActivity fills the counters of `window` with the events stored
+// for the repository between window.From and window.To, both included
+func (s *sqlStore) CountActivity(owner, name string, window *common.ActivityWindow) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+	return s.queryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM stargazers
+				WHERE repository_id = $1 AND starred_at >= $2 AND starred_at <= $3)
+			+ (SELECT COUNT(*) FROM unstars
+				WHERE repository_id = $1 AND starred_at >= $2 AND starred_at <= $3),
+			(SELECT COUNT(*) FROM unstars
+				WHERE repository_id = $1 AND unstarred_at >= $2 AND unstarred_at <= $3),
+			(SELECT COUNT(*) FROM commits
+				WHERE repository_id = $1 AND committed_at >= $2 AND committed_at <= $3),
+			(SELECT COUNT(*) FROM forks
+				WHERE repository_id = $1 AND created_at >= $2 AND created_at <= $3),
+			(SELECT COUNT(*) FROM issues
+				WHERE repository_id = $1 AND is_pull_request = false AND created_at >= $2 AND created_at <= $3),
+			(SELECT COUNT(*) FROM issues
+				WHERE repository_id = $1 AND is_pull_request = true AND created_at >= $2 AND created_at <= $3)`,
+		id, window.From.UTC(), window.To.UTC()).Scan(
+		&window.Stars,
+		&window.Unstars,
+		&window.Commits,
+		&window.Forks,
+		&window.Issues,
+		&window.PullRequests,
+	)
+}
+
+// QueryEnabledRepos fetches the repositories that should be refreshed
+// periodically, along with the outcome of their last refresh
+func (s *sqlStore) QueryEnabledRepos(repos *[]common.RefreshStatus) error {
+	rows, err := s.query(`
+		SELECT
+			repository_owner,
+			repository_name,
+			last_refresh_success_at,
+			last_refresh_failure_at,
+			COALESCE(last_refresh_error, '')
+		FROM repositories
+		WHERE enabled = true
+		ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		repo := common.RefreshStatus{}
+		err = rows.Scan(
+			&repo.OwnerName,
+			&repo.Name,
+			&repo.LastSuccessAt,
+			&repo.LastFailureAt,
+			&repo.LastError,
+		)
+		if err != nil {
+			return err
+		}
+		*repos = append(*repos, repo)
+	}
+	return rows.Err()
+}
+
+// RepoExists reports whether the repository is tracked
+func (s *sqlStore) RepoExists(owner, name string) (bool, error) {
+	_, err := s.repositoryID(owner, name)
+	switch err.(type) {
+	case nil:
+		return true, nil
+	case common.ErrRepoNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// DeleteRepo removes a repository, along with everything stored about it
+func (s *sqlStore) DeleteRepo(owner, name string) error {
+	res, err := s.exec("DELETE FROM repositories WHERE repository_owner=$1 AND repository_name=$2", owner, name)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// SetRepoEnabled includes a repository in the periodic refreshes, or
+// excludes it
+func (s *sqlStore) SetRepoEnabled(owner, name string, enabled bool) error {
+	res, err := s.exec("UPDATE repositories SET enabled = $1 WHERE repository_owner=$2 AND repository_name=$3", enabled, owner, name)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// requireAffected returns ErrRepoNotFound if the statement matched no
+// repository
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return common.ErrRepoNotFound("Repository not found")
+	}
+	return nil
+}
+
+// SetRefreshSuccess records the time of the last successful refresh
+// of a repository
+func (s *sqlStore) SetRefreshSuccess(owner, name string, at time.Time) error {
+	_, err := s.exec(`
+		UPDATE repositories SET
+			last_refresh_success_at = $1,
+			last_refresh_error = NULL
+		WHERE repository_owner=$2 AND repository_name=$3`,
+		at.UTC(), owner, name)
+	return err
+}
+
+// SetRefreshFailure records the time and the cause of the last failed
+// refresh of a repository
+func (s *sqlStore) SetRefreshFailure(owner, name string, at time.Time, cause error) error {
+	_, err := s.exec(`
+		UPDATE repositories SET
+			last_refresh_failure_at = $1,
+			last_refresh_error = $2
+		WHERE repository_owner=$3 AND repository_name=$4`,
+		at.UTC(), cause.Error(), owner, name)
+	return err
+}
+
+func (s *sqlStore) LatestStarredAt(owner, name string) (time.Time, error) {
+	var latest time.Time
+	err := s.queryRow(`
+		SELECT stargazers.starred_at
+		FROM stargazers
+		JOIN repositories ON repositories.id = stargazers.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		ORDER BY stargazers.starred_at DESC
+		LIMIT 1`, owner, name).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return latest, err
+}
+
+func (s *sqlStore) AddStargazers(owner, name string, stargazers []common.Stargazer) error {
+	if len(stargazers) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO stargazers (repository_id, user_login, starred_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (repository_id, user_login) DO NOTHING`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, stargazer := range stargazers {
+		_, err = stmt.Exec(id, stargazer.Login, stargazer.StarredAt.UTC())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) QueryStarDates(owner, name string, dates *[]time.Time) error {
+	rows, err := s.query(`
+		SELECT starred_at FROM (
+			SELECT stargazers.starred_at
+			FROM stargazers
+			JOIN repositories ON repositories.id = stargazers.repository_id
+			WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+			UNION ALL
+			SELECT unstars.starred_at
+			FROM unstars
+			JOIN repositories ON repositories.id = unstars.repository_id
+			WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		) AS stars
+		ORDER BY starred_at`, owner, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var starredAt time.Time
+		err = rows.Scan(&starredAt)
+		if err != nil {
+			return err
+		}
+		*dates = append(*dates, starredAt)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) QueryStargazers(owner, name string, stargazers *[]common.Stargazer) error {
+	rows, err := s.query(`
+		SELECT stargazers.user_login, stargazers.starred_at
+		FROM stargazers
+		JOIN repositories ON repositories.id = stargazers.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		ORDER BY stargazers.starred_at`, owner, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		stargazer := common.Stargazer{}
+		err = rows.Scan(&stargazer.Login, &stargazer.StarredAt)
+		if err != nil {
+			return err
+		}
+		*stargazers = append(*stargazers, stargazer)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) AddUnstars(owner, name string, unstars []common.Unstar) error {
+	if len(unstars) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, unstar := range unstars {
+		_, err = tx.Exec(s.rebind(`
+			INSERT INTO unstars (repository_id, user_login, starred_at, unstarred_at)
+			VALUES ($1, $2, $3, $4)`),
+			id, unstar.Login, unstar.StarredAt.UTC(), unstar.UnstarredAt.UTC())
+		if err == nil {
+			_, err = tx.Exec(s.rebind("DELETE FROM stargazers WHERE repository_id=$1 AND user_login=$2"), id, unstar.Login)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) UnstarsCheckedAt(owner, name string) (time.Time, error) {
+	var checkedAt *time.Time
+	err := s.queryRow("SELECT unstars_checked_at FROM repositories WHERE repository_owner=$1 AND repository_name=$2", owner, name).Scan(&checkedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return time.Time{}, common.ErrRepoNotFound("Repository not found")
+	case err != nil:
+		return time.Time{}, err
+	case checkedAt == nil:
+		return time.Time{}, nil
+	}
+	return *checkedAt, nil
+}
+
+func (s *sqlStore) SetUnstarsChecked(owner, name string, at time.Time) error {
+	_, err := s.exec("UPDATE repositories SET unstars_checked_at = $1 WHERE repository_owner=$2 AND repository_name=$3",
+		at.UTC(), owner, name)
+	return err
+}
+
+func (s *sqlStore) QueryUnstars(owner, name string, unstars *[]common.Unstar) error {
+	rows, err := s.query(`
+		SELECT unstars.user_login, unstars.starred_at, unstars.unstarred_at
+		FROM unstars
+		JOIN repositories ON repositories.id = unstars.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		ORDER BY unstars.unstarred_at`, owner, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		unstar := common.Unstar{}
+		err = rows.Scan(&unstar.Login, &unstar.StarredAt, &unstar.UnstarredAt)
+		if err != nil {
+			return err
+		}
+		*unstars = append(*unstars, unstar)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) ReplaceStarBuckets(owner, name, granularity string, buckets []common.StarBucket) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(s.rebind("DELETE FROM star_buckets WHERE repository_id = $1 AND granularity = $2"), id, granularity)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO star_buckets (repository_id, granularity, period, period_start, count, cumulative)
+		VALUES ($1, $2, $3, $4, $5, $6)`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, bucket := range buckets {
+		_, err = stmt.Exec(id, granularity, bucket.Period, bucket.Start.UTC(), bucket.Count, bucket.Cumulative)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) QueryStarBuckets(owner, name, granularity string, buckets *[]common.StarBucket) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+	rows, err := s.query(`
+		SELECT period, period_start, count, cumulative
+		FROM star_buckets
+		WHERE repository_id = $1 AND granularity = $2
+		ORDER BY period_start`, id, granularity)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		bucket := common.StarBucket{}
+		err = rows.Scan(&bucket.Period, &bucket.Start, &bucket.Count, &bucket.Cumulative)
+		if err != nil {
+			return err
+		}
+		*buckets = append(*buckets, bucket)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) ReplaceSpikeEvents(owner, name string, events []common.SpikeEvent) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(s.rebind("DELETE FROM spike_events WHERE repository_id = $1"), id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO spike_events (repository_id, metric, day, count, baseline, magnitude)
+		VALUES ($1, $2, $3, $4, $5, $6)`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, event := range events {
+		_, err = stmt.Exec(id, event.Metric, event.Date.UTC(), event.Count, event.Baseline, event.Magnitude)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) QuerySpikeEvents(owner, name string, events *[]common.SpikeEvent) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+	rows, err := s.query(`
+		SELECT metric, day, count, baseline, magnitude
+		FROM spike_events
+		WHERE repository_id = $1
+		ORDER BY day, metric`, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		event := common.SpikeEvent{}
+		err = rows.Scan(&event.Metric, &event.Date, &event.Count, &event.Baseline, &event.Magnitude)
+		if err != nil {
+			return err
+		}
+		*events = append(*events, event)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) LatestForkCreatedAt(owner, name string) (time.Time, error) {
+	var latest time.Time
+	err := s.queryRow(`
+		SELECT forks.created_at
+		FROM forks
+		JOIN repositories ON repositories.id = forks.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		ORDER BY forks.created_at DESC
+		LIMIT 1`, owner, name).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return latest, err
+}
+
+func (s *sqlStore) AddForks(owner, name string, forks []common.Fork) error {
+	if len(forks) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO forks (repository_id, id_of_fork_on_github, full_name, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (repository_id, id_of_fork_on_github) DO NOTHING`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, fork := range forks {
+		_, err = stmt.Exec(id, fork.ID, fork.FullName, fork.CreatedAt.UTC())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) QueryForkDates(owner, name string, dates *[]time.Time) error {
+	rows, err := s.query(`
+		SELECT forks.created_at
+		FROM forks
+		JOIN repositories ON repositories.id = forks.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		ORDER BY forks.created_at`, owner, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var createdAt time.Time
+		err = rows.Scan(&createdAt)
+		if err != nil {
+			return err
+		}
+		*dates = append(*dates, createdAt)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) QueryCommitSHAs(owner, name string, shas *[]string) error {
+	rows, err := s.query(`
+		SELECT commits.sha
+		FROM commits
+		JOIN repositories ON repositories.id = commits.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2`, owner, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sha string
+		err = rows.Scan(&sha)
+		if err != nil {
+			return err
+		}
+		*shas = append(*shas, sha)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) AddCommits(owner, name string, commits []common.Commit) error {
+	if len(commits) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO commits (repository_id, sha, author, committed_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (repository_id, sha) DO NOTHING`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, commit := range commits {
+		_, err = stmt.Exec(id, commit.SHA, commit.Author, commit.CommittedAt.UTC())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) QueryCommitDates(owner, name string, dates *[]time.Time) error {
+	rows, err := s.query(`
+		SELECT commits.committed_at
+		FROM commits
+		JOIN repositories ON repositories.id = commits.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		ORDER BY commits.committed_at`, owner, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var committedAt time.Time
+		err = rows.Scan(&committedAt)
+		if err != nil {
+			return err
+		}
+		*dates = append(*dates, committedAt)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) AddContributors(owner, name string, contributors []common.Contributor) error {
+	if len(contributors) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO contributors (repository_id, login, contributions)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (repository_id, login) DO UPDATE SET
+			contributions = excluded.contributions`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, contributor := range contributors {
+		_, err = stmt.Exec(id, contributor.Login, contributor.Contributions)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) AddContributorWeeks(owner, name string, weeks []common.ContributorWeek) error {
+	if len(weeks) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO contributor_weeks (repository_id, login, week, commits)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (repository_id, login, week) DO UPDATE SET
+			commits = excluded.commits`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, week := range weeks {
+		_, err = stmt.Exec(id, week.Login, week.Week.UTC(), week.Commits)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) CountContributors(owner, name string) (int, error) {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = s.queryRow("SELECT COUNT(*) FROM contributors WHERE repository_id=$1", id).Scan(&count)
+	return count, err
+}
+
+func (s *sqlStore) QueryContributorWeeks(owner, name string, weeks *[]common.ContributorWeek) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+	rows, err := s.query(`
+		SELECT login, week, commits
+		FROM contributor_weeks
+		WHERE repository_id=$1 AND commits > 0
+		ORDER BY week`, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		week := common.ContributorWeek{}
+		err = rows.Scan(&week.Login, &week.Week, &week.Commits)
+		if err != nil {
+			return err
+		}
+		*weeks = append(*weeks, week)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) AddReleases(owner, name string, releases []common.Release) error {
+	if len(releases) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// a tag never overwrites the release published for it
+	releaseStmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO releases (repository_id, tag, name, published_at, prerelease, downloads)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (repository_id, tag) DO UPDATE SET
+			name = excluded.name,
+			published_at = excluded.published_at,
+			prerelease = excluded.prerelease,
+			downloads = excluded.downloads`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer releaseStmt.Close()
+	tagStmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO releases (repository_id, tag, name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (repository_id, tag) DO NOTHING`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer tagStmt.Close()
+	for _, release := range releases {
+		if release.PublishedAt == nil {
+			_, err = tagStmt.Exec(id, release.Tag, release.Name)
+		} else {
+			_, err = releaseStmt.Exec(id, release.Tag, release.Name, release.PublishedAt.UTC(), release.Prerelease, release.Downloads)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) QueryReleases(owner, name string, releases *[]common.Release) error {
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+	rows, err := s.query(`
+		SELECT tag, name, published_at, prerelease, downloads
+		FROM releases
+		WHERE repository_id=$1
+		ORDER BY published_at IS NULL, published_at, tag`, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		release := common.Release{}
+		err = rows.Scan(&release.Tag, &release.Name, &release.PublishedAt, &release.Prerelease, &release.Downloads)
+		if err != nil {
+			return err
+		}
+		*releases = append(*releases, release)
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) IssuesSyncedAt(owner, name string) (time.Time, error) {
+	var syncedAt *time.Time
+	err := s.queryRow("SELECT issues_synced_at FROM repositories WHERE repository_owner=$1 AND repository_name=$2", owner, name).Scan(&syncedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return time.Time{}, common.ErrRepoNotFound("Repository not found")
+	case err != nil:
+		return time.Time{}, err
+	case syncedAt == nil:
+		return time.Time{}, nil
+	}
+	return *syncedAt, nil
+}
+
+func (s *sqlStore) SetIssuesSynced(owner, name string, at time.Time) error {
+	_, err := s.exec("UPDATE repositories SET issues_synced_at = $1 WHERE repository_owner=$2 AND repository_name=$3",
+		at.UTC(), owner, name)
+	return err
+}
+
+func (s *sqlStore) AddIssues(owner, name string, issues []common.Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		INSERT INTO issues (
+			repository_id,
+			number,
+			is_pull_request,
+			title,
+			author,
+			state,
+			labels,
+			created_at,
+			updated_at,
+			closed_at
+			)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (repository_id, number) DO UPDATE SET
+			title = excluded.title,
+			state = excluded.state,
+			labels = excluded.labels,
+			updated_at = excluded.updated_at,
+			closed_at = excluded.closed_at`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, issue := range issues {
+		labels, err := json.Marshal(issue.Labels)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		var closedAt *time.Time
+		if issue.ClosedAt != nil {
+			t := issue.ClosedAt.UTC()
+			closedAt = &t
+		}
+		_, err = stmt.Exec(
+			id,
+			issue.Number,
+			issue.PullRequest,
+			issue.Title,
+			issue.Author,
+			issue.State,
+			string(labels),
+			issue.CreatedAt.UTC(),
+			issue.UpdatedAt.UTC(),
+			closedAt,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) AddPullRequestMerges(owner, name string, merges map[int]time.Time) error {
+	if len(merges) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for number, mergedAt := range merges {
+		_, err = tx.Exec(s.rebind("UPDATE issues SET merged_at = $1 WHERE repository_id = $2 AND number = $3"),
+			mergedAt.UTC(), id, number)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) AddIssueComments(owner, name string, comments []common.IssueComment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	id, err := s.repositoryID(owner, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.rebind(`
+		UPDATE issues SET first_response_at = $1
+		WHERE repository_id = $2 AND number = $3 AND author <> $4
+			AND (first_response_at IS NULL OR first_response_at > $1)`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, comment := range comments {
+		_, err = stmt.Exec(comment.CreatedAt.UTC(), id, comment.Number, comment.Author)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) QueryIssues(owner, name string, issues *[]common.Issue) error {
+	rows, err := s.query(`
+		SELECT
+			issues.number,
+			issues.is_pull_request,
+			issues.title,
+			issues.author,
+			issues.state,
+			issues.labels,
+			issues.created_at,
+			issues.updated_at,
+			issues.closed_at,
+			issues.merged_at,
+			issues.first_response_at
+		FROM issues
+		JOIN repositories ON repositories.id = issues.repository_id
+		WHERE repositories.repository_owner=$1 AND repositories.repository_name=$2
+		ORDER BY issues.created_at`, owner, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		issue := common.Issue{}
+		var labels string
+		err = rows.Scan(
+			&issue.Number,
+			&issue.PullRequest,
+			&issue.Title,
+			&issue.Author,
+			&issue.State,
+			&labels,
+			&issue.CreatedAt,
+			&issue.UpdatedAt,
+			&issue.ClosedAt,
+			&issue.MergedAt,
+			&issue.FirstResponseAt,
+		)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal([]byte(labels), &issue.Labels)
+		if err != nil {
+			return err
+		}
+		*issues = append(*issues, issue)
+	}
+	return rows.Err()
+}
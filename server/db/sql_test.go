@@ -0,0 +1,71 @@
+package db
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	last := common.RepositorySummary{
+		ID:                   42,
+		Name:                 "gitometer",
+		TotalStars:           1500,
+		TotalCommits:         320,
+		StarsCountLastWeek:   12,
+		StarsCountLast4Weeks: 61,
+	}
+	tests := []struct {
+		column string
+		value  interface{}
+	}{
+		{"total_stars", int64(1500)},
+		{"total_commits", int64(320)},
+		{"stars_count_last_week", int64(12)},
+		{"stars_count_last_4_weeks", int64(61)},
+		{"repository_name", "gitometer"},
+		{"id", int64(42)},
+	}
+	for _, test := range tests {
+		t.Run(test.column, func(t *testing.T) {
+			value, id, err := decodeCursor(encodeCursor(last, test.column), test.column)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.value || id != 42 {
+				t.Fatalf("got %#v and id %d, want %#v and id 42", value, id, test.value)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	tests := []struct {
+		name   string
+		cursor string
+		column string
+	}{
+		{"not base64", "not a cursor!", "total_stars"},
+		{"not json", encode("1500,42"), "total_stars"},
+		{"not a list", encode(`{"value":1500}`), "total_stars"},
+		{"one value", encode("[1500]"), "total_stars"},
+		{"three values", encode("[1500,42,1]"), "total_stars"},
+		{"string id", encode(`[1500,"42"]`), "total_stars"},
+		{"fractional id", encode("[1500,4.2]"), "total_stars"},
+		{"string value", encode(`["1500",42]`), "total_stars"},
+		{"fractional value", encode("[15.5,42]"), "total_stars"},
+		{"number for a name", encode("[1500,42]"), "repository_name"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := decodeCursor(test.cursor, test.column)
+			if _, ok := err.(common.ErrBadRequest); !ok {
+				t.Fatalf("got error %v, want a common.ErrBadRequest", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+
+	// SQLite drivers
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	sqlitepath = "GITOMETER_SQLITE_PATH"
+
+	defaultSQLitePath = "gitometer.db"
+)
+
+// newSQLiteStore opens the SQLite database file set in the
+// GITOMETER_SQLITE_PATH environment variable, creating it if needed
+func newSQLiteStore() (Store, error) {
+	path := os.Getenv(sqlitepath)
+	if path == "" {
+		path = defaultSQLitePath
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time
+	db.SetMaxOpenConns(1)
+
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: sqliteDialect}, nil
+}
@@ -5,11 +5,17 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/flaviocopes/gitometer/server/common"
 	"github.com/flaviocopes/gitometer/server/db"
 	"github.com/flaviocopes/gitometer/server/github"
+	"github.com/flaviocopes/gitometer/server/jobs"
+	"github.com/flaviocopes/gitometer/server/scheduler"
+	"github.com/flaviocopes/gitometer/server/stats"
 )
 
 func corsHandler(h http.Handler) http.HandlerFunc {
@@ -26,15 +32,38 @@ func main() {
 	db.InitDb()
 	defer db.Close()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	err := db.MigrateUp()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the scheduler refreshes repositories through the import jobs
+	jobs.Start()
+	scheduler.Start()
+
 	http.HandleFunc("/api/index", indexHandler)
 	http.HandleFunc("/api/repo/", getRepoHandler)
 	http.HandleFunc("/api/repo", addRepoHandler)
+	http.HandleFunc("/api/jobs/", jobHandler)
+	http.HandleFunc("/api/owners/", ownerHandler)
+	http.HandleFunc("/api/orgs/", orgImportHandler)
+	http.HandleFunc("/api/users/", userImportHandler)
+	http.HandleFunc("/api/github/ratelimit", rateLimitHandler)
+	http.HandleFunc("/api/scheduler/status", schedulerStatusHandler)
 	log.Fatal(http.ListenAndServe("localhost:8000", nil))
 }
 
 func setupResponse(w *http.ResponseWriter, req *http.Request) {
 	(*w).Header().Set("Access-Control-Allow-Origin", "*")
-	(*w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+	(*w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
 	(*w).Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 }
 
@@ -43,9 +72,14 @@ func setupResponse(w *http.ResponseWriter, req *http.Request) {
 func parseParams(req *http.Request, prefix string, num int) ([]string, error) {
 	url := strings.TrimPrefix(req.URL.Path, prefix)
 	params := strings.Split(url, "/")
-	if len(params) != num || len(params[0]) == 0 || len(params[1]) == 0 {
+	if len(params) != num {
 		return nil, fmt.Errorf("Bad format. Expecting exactly %d params", num)
 	}
+	for _, param := range params {
+		if len(param) == 0 {
+			return nil, fmt.Errorf("Bad format. Expecting exactly %d params", num)
+		}
+	}
 	return params, nil
 }
 
@@ -56,34 +90,229 @@ func indexHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	opt, err := parseListOptions(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
 	repos := common.Repositories{}
 
-	err := db.QueryRepos(&repos)
+	err = db.QueryRepos(opt, &repos)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
 
 	out, err := json.Marshal(repos)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
 
-	fmt.Fprintf(w, string(out))
+	w.Write(out)
+}
+
+const (
+	defaultIndexLimit = 100
+	maxIndexLimit     = 1000
+)
+
+// parseListOptions reads the pagination, sorting and filtering query
+// parameters of the index. Repositories are sorted by stars, most
+// starred first, unless asked otherwise
+func parseListOptions(req *http.Request) (common.RepoListOptions, error) {
+	query := req.URL.Query()
+	opt := common.RepoListOptions{
+		Limit:  defaultIndexLimit,
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+		Order:  query.Get("order"),
+		Owner:  query.Get("owner"),
+		Query:  query.Get("q"),
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxIndexLimit {
+			return opt, common.ErrBadRequest(fmt.Sprintf("limit must be a number between 1 and %d", maxIndexLimit))
+		}
+		opt.Limit = limit
+	}
+	if opt.Sort == "" {
+		opt.Sort = "stars"
+	}
+	if !db.ValidSort(opt.Sort) {
+		return opt, common.ErrBadRequest("sort must be one of stars, commits, stars_last_week, stars_last_4_weeks, name, added_at")
+	}
+	switch opt.Order {
+	case "":
+		opt.Order = "desc"
+		if opt.Sort == "name" {
+			opt.Order = "asc"
+		}
+	case "asc", "desc":
+	default:
+		return opt, common.ErrBadRequest("order must be asc or desc")
+	}
+	return opt, nil
+}
+
+// ownerHandler marshals the overview of the tracked repositories of an
+// owner as JSON
+func ownerHandler(w http.ResponseWriter, req *http.Request) {
+	setupResponse(&w, req)
+	if (*req).Method == "OPTIONS" {
+		return
+	}
+	params, err := parseParams(req, "/api/owners/", 1)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	owner := params[0]
+
+	var repos []common.OwnerRepository
+	err = db.QueryOwnerRepos(owner, &repos)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(repos) == 0 {
+		writeError(w, common.ErrRepoNotFound("No repository tracked for this owner"))
+		return
+	}
+	var stars, commits []time.Time
+	err = db.QueryOwnerStarDates(owner, &stars)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = db.QueryOwnerCommitDates(owner, &commits)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out, err := json.Marshal(stats.OwnerStats(owner, repos, stars, commits))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
 }
 
 // queryRepo first fetches the repository, and if nothing is wrong
-// it returns the result of fetchData()
-func queryRepo(repo *common.Repository) (*common.RepoData, error) {
+// it adds the star growth series computed from the stored stars, and
+// the star history bucketed by `granularity`, annotated with the spikes
+func queryRepo(repo *common.Repository, granularity string) (*common.RepoData, error) {
 	data := common.RepoData{}
 	err := db.FetchRepo(repo, &data)
 	if err != nil {
 		return nil, err
 	}
+
+	stars, unstarDates, err := queryStarDates(repo.OwnerName, repo.Name)
+	if err != nil {
+		return nil, err
+	}
+	data.Repository.StarsGrowthPerWeek = stats.StarGrowth(stars, unstarDates, stats.Week)
+	data.Repository.StarsGrowthPerMonth = stats.StarGrowth(stars, unstarDates, stats.Month)
+
+	monthly, err := starBuckets(repo.OwnerName, repo.Name, stats.Month, stars, unstarDates)
+	if err != nil {
+		return nil, err
+	}
+	data.Repository.StarsPerMonth, err = stats.StarsPerMonthGraph(monthly)
+	if err != nil {
+		return nil, err
+	}
+	data.Repository.StarsHistory = monthly
+	if granularity != stats.Month {
+		data.Repository.StarsHistory, err = starBuckets(repo.OwnerName, repo.Name, granularity, stars, unstarDates)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var spikes []common.SpikeEvent
+	err = db.QuerySpikeEvents(repo.OwnerName, repo.Name, &spikes)
+	if err != nil {
+		return nil, err
+	}
+	stats.AnnotateSpikes(data.Repository.StarsHistory, spikes, granularity)
+
+	var issues []common.Issue
+	err = db.QueryIssues(repo.OwnerName, repo.Name, &issues)
+	if err != nil {
+		return nil, err
+	}
+	data.Repository.Issues = stats.IssueStats(issues)
+
+	var releases []common.Release
+	err = db.QueryReleases(repo.OwnerName, repo.Name, &releases)
+	if err != nil {
+		return nil, err
+	}
+	data.Repository.Releases = stats.ReleaseStats(releases)
+
+	// the windows of the counters are compared with the ones of the same
+	// length before them
+	data.Repository.Trends = []common.CounterTrend{}
+	for _, window := range stats.CounterWindows(time.Now()) {
+		previous := window.Previous()
+		currentActivity := common.ActivityWindow{From: window.From, To: window.To.Add(-time.Nanosecond)}
+		previousActivity := common.ActivityWindow{From: previous.From, To: previous.To.Add(-time.Nanosecond)}
+		err = db.CountActivity(repo.OwnerName, repo.Name, &currentActivity)
+		if err != nil {
+			return nil, err
+		}
+		err = db.CountActivity(repo.OwnerName, repo.Name, &previousActivity)
+		if err != nil {
+			return nil, err
+		}
+		data.Repository.Trends = append(data.Repository.Trends, stats.Trends(window.Name, currentActivity, previousActivity)...)
+	}
+
 	return &data, nil
 }
 
+// queryStarDates fetches when the stored stars of a repository were given
+// and when the detected unstars happened
+func queryStarDates(owner, name string) ([]time.Time, []time.Time, error) {
+	var stars []time.Time
+	err := db.QueryStarDates(owner, name, &stars)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unstars []common.Unstar
+	err = db.QueryUnstars(owner, name, &unstars)
+	if err != nil {
+		return nil, nil, err
+	}
+	unstarDates := make([]time.Time, 0, len(unstars))
+	for _, unstar := range unstars {
+		unstarDates = append(unstarDates, unstar.UnstarredAt)
+	}
+	return stars, unstarDates, nil
+}
+
+// starBuckets fetches the star buckets of a repository for a
+// granularity. Only some granularities are stored, and only once the
+// repository was refreshed, the other ones are computed from `stars` and
+// `unstars`
+func starBuckets(owner, name, granularity string, stars, unstars []time.Time) ([]common.StarBucket, error) {
+	var buckets []common.StarBucket
+	err := db.QueryStarBuckets(owner, name, granularity, &buckets)
+	if err != nil {
+		return nil, err
+	}
+	if len(buckets) == 0 {
+		buckets = stats.StarBuckets(stars, unstars, granularity)
+	}
+	return buckets, nil
+}
+
 // getRepoHandler processes the response by parsing the params, then calling
 // `query()`, and marshaling the result in JSON format, sending it to
 // `http.ResponseWriter`.
@@ -94,8 +323,168 @@ func getRepoHandler(w http.ResponseWriter, req *http.Request) {
 	}
 	switch req.Method {
 	case "GET":
-		handleGetRepo(w, req)
+		switch repoAction(req) {
+		case "history":
+			handleGetRepoHistory(w, req)
+		case "contributors":
+			handleGetRepoContributors(w, req)
+		case "activity":
+			handleGetRepoActivity(w, req)
+		case "forecast":
+			handleGetRepoForecast(w, req)
+		case "events":
+			handleGetRepoEvents(w, req)
+		default:
+			handleGetRepo(w, req)
+		}
+	case "POST":
+		switch repoAction(req) {
+		case "refresh":
+			handleRefreshRepo(w, req)
+		default:
+			writeError(w, common.ErrMethodNotAllowed("Method not allowed"))
+		}
+	case "DELETE":
+		handleDeleteRepo(w, req)
+	case "PATCH":
+		handlePatchRepo(w, req)
+	default:
+		writeError(w, common.ErrMethodNotAllowed("Method not allowed"))
+	}
+}
+
+// repoAction returns the token following `/api/repo/{owner}/{name}/`,
+// or an empty string if the request targets the repository itself
+func repoAction(req *http.Request) string {
+	params := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/repo/"), "/")
+	if len(params) < 3 {
+		return ""
+	}
+	return params[2]
+}
+
+// handleRefreshRepo queues a refresh of a tracked repository, answering
+// with the job. A refresh already queued or running for the repository
+// is reused. With `?wait=true` the response waits for the refresh to
+// finish and contains the finished job, or the error it failed with
+func handleRefreshRepo(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 3)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	owner, name := params[0], params[1]
+
+	exists, err := db.RepoExists(owner, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !exists {
+		writeError(w, common.ErrRepoNotFound("Repository not found"))
+		return
+	}
+
+	job, err := jobs.EnqueueRefresh(owner, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if req.URL.Query().Get("wait") != "true" {
+		out, err := json.Marshal(job)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(out)
+		return
+	}
+
+	job, err = jobs.Wait(req.Context(), job.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	switch job.State {
+	case common.JobFailed:
+		writeError(w, job.Err)
+		return
+	case common.JobCanceled:
+		writeError(w, common.ErrRepoNotFound("Repository deleted during the refresh"))
+		return
+	}
+
+	// the job rather than the repository data, which FetchRepo refuses
+	// to serve until the repository is initialized
+	out, err := json.Marshal(job)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+// handleDeleteRepo stops tracking the repository and removes its data
+func handleDeleteRepo(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 2)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// the pending imports and refreshes would add the repository back,
+	// the running ones may be storing it right now
+	for _, id := range jobs.Cancel(params[0], params[1]) {
+		_, err = jobs.Wait(req.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	err = db.DeleteRepo(params[0], params[1])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type repoSettings struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// handlePatchRepo enables or disables the periodic refresh of the
+// repository. Disabled repositories keep their data
+func handlePatchRepo(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 2)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+
+	var settings repoSettings
+	err = json.NewDecoder(req.Body).Decode(&settings)
+	if err != nil {
+		writeError(w, common.ErrBadRequest("Invalid JSON body: "+err.Error()))
+		return
+	}
+	if settings.Enabled == nil {
+		writeError(w, common.ErrBadRequest("Missing parameter enabled"))
+		return
 	}
+
+	err = db.SetRepoEnabled(params[0], params[1], *settings.Enabled)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func addRepoHandler(w http.ResponseWriter, req *http.Request) {
@@ -117,49 +506,429 @@ func handleAddNewRepo(w http.ResponseWriter, req *http.Request) {
 	var data newRepoData
 	err := decoder.Decode(&data)
 	if err != nil {
-		panic(err)
+		writeError(w, common.ErrBadRequest("Invalid JSON body: "+err.Error()))
+		return
 	}
 
 	owner := data.Owner
 	name := data.Name
 
 	if owner == "" || name == "" {
-		http.Error(w, "Missing parameter name or owner", 500)
+		writeError(w, common.ErrBadRequest("Missing parameter name or owner"))
+		return
+	}
+
+	// fail early on repositories the import would not be able to read
+	err = github.CheckRepo(req.Context(), owner, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	job, err := jobs.Enqueue(owner, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out, err := json.Marshal(job)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
-	github.AddRepoToDb(owner, name)
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(out)
+}
+
+// jobHandler marshals the state of the import job as JSON
+func jobHandler(w http.ResponseWriter, req *http.Request) {
+	setupResponse(&w, req)
+	if (*req).Method == "OPTIONS" {
+		return
+	}
+	params, err := parseParams(req, "/api/jobs/", 1)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
 
-	fmt.Fprintf(w, string("ok"))
+	job, err := jobs.Get(params[0])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out, err := json.Marshal(job)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
 }
 
 func handleGetRepo(w http.ResponseWriter, req *http.Request) {
 	repo := common.Repository{}
 	params, err := parseParams(req, "/api/repo/", 2)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, common.ErrBadRequest(err.Error()))
 		return
 	}
 	repo.OwnerName = params[0]
 	repo.Name = params[1]
 
-	data, err := queryRepo(&repo)
+	granularity := req.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = stats.Month
+	}
+	if !stats.ValidGranularity(granularity) {
+		writeError(w, common.ErrBadRequest("granularity must be one of day, week, month, quarter, year"))
+		return
+	}
+
+	data, err := queryRepo(&repo, granularity)
 	if err != nil {
-		switch err.(type) {
-		case common.ErrRepoNotFound:
-			http.Error(w, err.Error(), 404)
-		case common.ErrRepoNotInitialized:
-			http.Error(w, err.Error(), 401)
-		default:
-			http.Error(w, err.Error(), 500)
-		}
+		writeError(w, err)
 		return
 	}
 
 	out, err := json.Marshal(data)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+// parseTimeParam parses the `key` query parameter either as RFC3339 or as
+// a plain date. Missing parameters return a zero time.Time. When `endOfDay`
+// is set, a plain date is moved to the last second of that day so the
+// range includes it
+func parseTimeParam(req *http.Request, key string, endOfDay bool) (time.Time, error) {
+	value := req.URL.Query().Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Bad format for %s. Expecting RFC3339 or YYYY-MM-DD", key)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
+
+func handleGetRepoHistory(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 3)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	from, err := parseTimeParam(req, "from", false)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	to, err := parseTimeParam(req, "to", true)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+
+	history := common.RepositoryHistory{}
+	err = db.QueryRepoHistory(params[0], params[1], from, to, &history)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out, err := json.Marshal(history)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+func handleGetRepoContributors(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 3)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	owner, name := params[0], params[1]
+
+	total, err := db.CountContributors(owner, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var weeks []common.ContributorWeek
+	err = db.QueryContributorWeeks(owner, name, &weeks)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	contributors := stats.ContributorStats(total, weeks, time.Now().AddDate(-1, 0, 0))
+	contributors.OwnerName = owner
+	contributors.Name = name
+
+	out, err := json.Marshal(contributors)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+// handleGetRepoActivity counts the events of the repository in each
+// window of `?window=7d,30d`, ending now, or between `from` and `to`
+func handleGetRepoActivity(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 3)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	owner, name := params[0], params[1]
+
+	from, err := parseTimeParam(req, "from", false)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	to, err := parseTimeParam(req, "to", true)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+
+	now := time.Now()
+	var windows []common.ActivityWindow
+	if !from.IsZero() || !to.IsZero() {
+		if to.IsZero() {
+			to = now
+		}
+		windows = append(windows, common.ActivityWindow{Window: "custom", From: from, To: to})
+	} else {
+		labels := stats.DefaultWindows
+		if value := req.URL.Query().Get("window"); value != "" {
+			labels = strings.Split(value, ",")
+		}
+		for _, label := range labels {
+			start, err := stats.WindowStart(label, now)
+			if err != nil {
+				writeError(w, common.ErrBadRequest(err.Error()))
+				return
+			}
+			windows = append(windows, common.ActivityWindow{Window: label, From: start, To: now})
+		}
+	}
+
+	activity := common.RepositoryActivity{Name: name, OwnerName: owner}
+	for i := range windows {
+		err = db.CountActivity(owner, name, &windows[i])
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	activity.Windows = windows
+
+	out, err := json.Marshal(activity)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+// handleGetRepoForecast projects the stars of a repository `horizon`
+// months ahead from its monthly star history. With `?target=N`, the
+// response also tells when each model expects the repository to reach
+// N stars
+func handleGetRepoForecast(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 3)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	owner, name := params[0], params[1]
+
+	query := req.URL.Query()
+	metric := query.Get("metric")
+	if metric == "" {
+		metric = "stars"
+	}
+	if metric != "stars" {
+		writeError(w, common.ErrBadRequest(fmt.Sprintf("Unknown metric %q. Only stars can be forecast", metric)))
+		return
+	}
+	horizon := query.Get("horizon")
+	if horizon == "" {
+		horizon = "12m"
+	}
+	months, err := stats.ParseHorizon(horizon)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	target := 0
+	if value := query.Get("target"); value != "" {
+		target, err = strconv.Atoi(value)
+		if err != nil || target < 1 {
+			writeError(w, common.ErrBadRequest("target must be a positive number"))
+			return
+		}
+	}
+
+	var buckets []common.StarBucket
+	err = db.QueryStarBuckets(owner, name, stats.Month, &buckets)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(buckets) == 0 {
+		stars, unstarDates, err := queryStarDates(owner, name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		buckets = stats.StarBuckets(stars, unstarDates, stats.Month)
+	}
+
+	forecast := common.Forecast{
+		Name:      name,
+		OwnerName: owner,
+		Metric:    metric,
+		Horizon:   months,
+		Target:    target,
+		Models:    []common.ForecastModel{},
+	}
+	if len(buckets) > 0 {
+		forecast.Current = buckets[len(buckets)-1].Cumulative
+	}
+	// the current month is not over, only complete months are fitted
+	currentMonth := stats.PeriodStart(time.Now(), stats.Month)
+	var values []float64
+	for _, bucket := range buckets {
+		if !bucket.Start.Before(currentMonth) {
+			break
+		}
+		values = append(values, float64(bucket.Cumulative))
+		if target > 0 && forecast.ReachedAt == nil && bucket.Cumulative >= target {
+			reachedAt := stats.NextPeriod(bucket.Start, stats.Month)
+			forecast.ReachedAt = &reachedAt
+		}
+	}
+	var first time.Time
+	if len(buckets) > 0 {
+		first = buckets[0].Start
+	}
+	forecast.Models, err = stats.Forecast(first, values, months, float64(target))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	forecast.Best = stats.Best(forecast.Models)
+
+	out, err := json.Marshal(forecast)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+// handleGetRepoEvents lists the days the stars or the commits of a
+// repository spiked, optionally only the ones of `?metric=`
+func handleGetRepoEvents(w http.ResponseWriter, req *http.Request) {
+	params, err := parseParams(req, "/api/repo/", 3)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	owner, name := params[0], params[1]
+
+	metric := req.URL.Query().Get("metric")
+	if metric != "" && metric != "stars" && metric != "commits" {
+		writeError(w, common.ErrBadRequest(fmt.Sprintf("Unknown metric %q. Expecting stars or commits", metric)))
+		return
+	}
+
+	var spikes []common.SpikeEvent
+	err = db.QuerySpikeEvents(owner, name, &spikes)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	events := common.RepositoryEvents{Name: name, OwnerName: owner, Events: []common.SpikeEvent{}}
+	for _, spike := range spikes {
+		if metric == "" || spike.Metric == metric {
+			events.Events = append(events.Events, spike)
+		}
+	}
+
+	out, err := json.Marshal(events)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+// schedulerStatusHandler marshals the state of the background refresh
+// scheduler as JSON
+func schedulerStatusHandler(w http.ResponseWriter, req *http.Request) {
+	setupResponse(&w, req)
+	if (*req).Method == "OPTIONS" {
+		return
+	}
+
+	status := common.SchedulerStatus{}
+	scheduler.CurrentStatus(&status)
+
+	out, err := json.Marshal(status)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Write(out)
+}
+
+// rateLimitHandler marshals the remaining GitHub API budget as JSON
+func rateLimitHandler(w http.ResponseWriter, req *http.Request) {
+	setupResponse(&w, req)
+	if (*req).Method == "OPTIONS" {
+		return
+	}
+
+	status := common.RateLimitStatus{}
+	err := github.RateLimit(&status)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out, err := json.Marshal(status)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	fmt.Fprintf(w, string(out))
+	w.Write(out)
 }
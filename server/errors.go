@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// errorStatus maps the error types of the `common` package to the HTTP
+// status code returned to the client
+func errorStatus(err error) int {
+	switch err.(type) {
+	case common.ErrBadRequest:
+		return http.StatusBadRequest
+	case common.ErrRepoNotFound, common.ErrUpstreamNotFound, common.ErrJobNotFound:
+		return http.StatusNotFound
+	case common.ErrRepoNotInitialized:
+		return http.StatusUnauthorized
+	case common.ErrRateLimited:
+		return http.StatusTooManyRequests
+	case common.ErrUpstreamUnavailable:
+		return http.StatusBadGateway
+	case common.ErrQueueFull:
+		return http.StatusServiceUnavailable
+	case common.ErrMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError sends the error to the client as a JSON body, with the
+// status code matching its type
+func writeError(w http.ResponseWriter, err error) {
+	out, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errorStatus(err))
+	w.Write(out)
+}
@@ -0,0 +1,148 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	"github.com/flaviocopes/gitometer/server/stats"
+	gogithub "github.com/google/go-github/github"
+)
+
+// fetchNewCommits returns the commits on `branch` not stored yet. The
+// commits are listed from the head of the branch, and a commit is new
+// when it can be reached from the head through its parents without going
+// through a stored commit. Listing stops once every new commit was seen,
+// so commits merged with old dates are found too, and the first import
+// walks the whole history. Pages are reported to `progress` if not nil
+func fetchNewCommits(ctx context.Context, owner, name, branch string, progress Progress) ([]common.Commit, error) {
+	client, err := getClientV3()
+	if err != nil {
+		return nil, err
+	}
+	var shas []string
+	err = db.QueryCommitSHAs(owner, name, &shas)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(shas))
+	for _, sha := range shas {
+		stored[sha] = true
+	}
+
+	opt := &gogithub.CommitsListOptions{
+		SHA:         branch,
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+	// wanted contains the new commits known from their children but not
+	// listed yet, it is nil until the head of the branch is listed.
+	// skipped contains the commits listed before any child asked for them
+	var wanted map[string]bool
+	skipped := make(map[string]*gogithub.RepositoryCommit)
+	taken := make(map[string]bool)
+	var results []common.Commit
+	for {
+		commits, resp, err := client.Repositories.ListCommits(ctx, owner, name, opt)
+		if isEmptyRepository(err) {
+			// empty repositories have no commits to list
+			return results, nil
+		}
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		page := opt.Page
+		if page == 0 {
+			page = 1
+		}
+		// the last page has no link to the last page
+		pagesTotal := resp.LastPage
+		if pagesTotal < page {
+			pagesTotal = page
+		}
+		for _, c := range commits {
+			sha := c.GetSHA()
+			if wanted == nil {
+				// the first commit listed is the head of the branch
+				wanted = make(map[string]bool)
+				if !stored[sha] {
+					wanted[sha] = true
+				}
+			}
+			if !wanted[sha] {
+				if !stored[sha] {
+					skipped[sha] = c
+				}
+				continue
+			}
+
+			pending := []*gogithub.RepositoryCommit{c}
+			for len(pending) > 0 {
+				c := pending[len(pending)-1]
+				pending = pending[:len(pending)-1]
+				delete(wanted, c.GetSHA())
+				taken[c.GetSHA()] = true
+				results = append(results, newCommit(c))
+				for _, parent := range c.Parents {
+					sha := parent.GetSHA()
+					switch {
+					case stored[sha] || taken[sha]:
+					case skipped[sha] != nil:
+						pending = append(pending, skipped[sha])
+						delete(skipped, sha)
+					default:
+						wanted[sha] = true
+					}
+				}
+			}
+		}
+		done := resp.NextPage == 0 || (wanted != nil && len(wanted) == 0)
+		if progress != nil {
+			if done {
+				// the remaining pages hold stored commits only
+				page = pagesTotal
+			}
+			progress(PhaseCommits, page, pagesTotal)
+		}
+		if done {
+			return results, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func newCommit(c *gogithub.RepositoryCommit) common.Commit {
+	// commits made with an email not linked to a GitHub account have no
+	// login
+	author := c.GetAuthor().GetLogin()
+	if author == "" {
+		author = c.GetCommit().GetAuthor().GetName()
+	}
+	return common.Commit{
+		SHA:         c.GetSHA(),
+		Author:      author,
+		CommittedAt: c.GetCommit().GetCommitter().GetDate(),
+	}
+}
+
+// getCommitsData computes the commits counters and the commits per month
+// graph from the commits stored for the repository, plus the `fetched`
+// ones not stored yet
+func getCommitsData(owner, name string, fetched []common.Commit) (int, int, int, string, error) {
+	var dates []time.Time
+	err := db.QueryCommitDates(owner, name, &dates)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+	for _, commit := range fetched {
+		dates = append(dates, commit.CommittedAt)
+	}
+
+	commitsPerMonth, err := stats.MonthlyGraph(dates)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+
+	commitsCountLast12Months, commitsCountLast4Weeks, commitsCountLastWeek := countInActivityWindows(dates)
+	return commitsCountLast12Months, commitsCountLast4Weeks, commitsCountLastWeek, commitsPerMonth, nil
+}
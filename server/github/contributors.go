@@ -0,0 +1,70 @@
+package github
+
+import (
+	"context"
+	"log"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	gogithub "github.com/google/go-github/github"
+)
+
+// syncContributors stores the contributors of the repository and the
+// commits each of them authored per week. GitHub answers the weekly
+// statistics only once it computed them: until then the ones stored are
+// kept, and the next refresh tries again
+func syncContributors(ctx context.Context, owner, name string) error {
+	client, err := getClientV3()
+	if err != nil {
+		return err
+	}
+
+	var contributors []common.Contributor
+	opt := &gogithub.ListContributorsOptions{
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+	for {
+		page, resp, err := client.Repositories.ListContributors(ctx, owner, name, opt)
+		if err != nil {
+			return wrapError(err)
+		}
+		for _, c := range page {
+			contributors = append(contributors, common.Contributor{
+				Login:         c.GetLogin(),
+				Contributions: c.GetContributions(),
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	err = db.AddContributors(owner, name, contributors)
+	if err != nil {
+		return err
+	}
+
+	stats, _, err := client.Repositories.ListContributorsStats(ctx, owner, name)
+	if _, ok := err.(*gogithub.AcceptedError); ok {
+		log.Printf("github: contributors statistics of %s/%s not ready yet", owner, name)
+		return nil
+	}
+	if err != nil {
+		return wrapError(err)
+	}
+	var weeks []common.ContributorWeek
+	for _, s := range stats {
+		login := s.GetAuthor().GetLogin()
+		for _, w := range s.Weeks {
+			if w.GetCommits() == 0 {
+				continue
+			}
+			weeks = append(weeks, common.ContributorWeek{
+				Login:   login,
+				Week:    w.GetWeek().Time,
+				Commits: w.GetCommits(),
+			})
+		}
+	}
+	return db.AddContributorWeeks(owner, name, weeks)
+}
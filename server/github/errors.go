@@ -0,0 +1,41 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	gogithub "github.com/google/go-github/github"
+)
+
+// wrapError translates the errors returned by the GitHub client into the
+// error types of the `common` package, so callers can tell a missing
+// repository from an exhausted rate limit or an outage
+func wrapError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *gogithub.RateLimitError:
+		return common.ErrRateLimited(fmt.Sprintf("GitHub rate limit exceeded, resets at %s", e.Rate.Reset.Format("15:04:05 MST")))
+	case *gogithub.AbuseRateLimitError:
+		return common.ErrRateLimited("GitHub abuse detection triggered, retry later")
+	case *gogithub.ErrorResponse:
+		switch {
+		case e.Response.StatusCode == http.StatusNotFound:
+			return common.ErrUpstreamNotFound("Repository not found on GitHub")
+		case e.Response.StatusCode >= 500:
+			return common.ErrUpstreamUnavailable(fmt.Sprintf("GitHub is unavailable: %s", e.Response.Status))
+		}
+	case *url.Error:
+		return common.ErrUpstreamUnavailable(fmt.Sprintf("GitHub is unreachable: %v", e.Err))
+	}
+	return err
+}
+
+// isEmptyRepository reports whether `err` is the 409 Conflict GitHub
+// answers when listing the commits of a repository with none
+func isEmptyRepository(err error) bool {
+	e, ok := err.(*gogithub.ErrorResponse)
+	return ok && e.Response.StatusCode == http.StatusConflict
+}
@@ -0,0 +1,24 @@
+package github
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	gogithub "github.com/google/go-github/github"
+)
+
+func errorResponse(status int) error {
+	return &gogithub.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{}}}
+}
+
+func TestIsEmptyRepository(t *testing.T) {
+	if !isEmptyRepository(errorResponse(http.StatusConflict)) {
+		t.Fatal("a 409 Conflict is not an empty repository")
+	}
+	for _, err := range []error{nil, errorResponse(http.StatusNotFound), common.ErrBadRequest("x")} {
+		if isEmptyRepository(err) {
+			t.Fatalf("%v is an empty repository", err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	"github.com/flaviocopes/gitometer/server/stats"
+	gogithub "github.com/google/go-github/github"
+)
+
+// fetchNewForks returns the forks created after the most recent one
+// stored, walking the forks from the newest
+func fetchNewForks(ctx context.Context, owner, name string) ([]common.Fork, error) {
+	client, err := getClientV3()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := db.LatestForkCreatedAt(owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &gogithub.RepositoryListForksOptions{
+		Sort:        "newest",
+		ListOptions: gogithub.ListOptions{PerPage: 100},
+	}
+	var results []common.Fork
+	for {
+		forks, resp, err := client.Repositories.ListForks(ctx, owner, name, opt)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		for _, f := range forks {
+			createdAt := f.GetCreatedAt().Time
+			if !createdAt.After(latest) {
+				return results, nil
+			}
+			results = append(results, common.Fork{
+				ID:        int64(f.GetID()),
+				FullName:  f.GetFullName(),
+				CreatedAt: createdAt,
+			})
+		}
+		if resp.NextPage == 0 {
+			return results, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// getForksData computes the forks counters and the forks per month graph
+// from the forks stored for the repository, plus the `fetched` ones not
+// stored yet
+func getForksData(owner, name string, fetched []common.Fork) (int, int, int, string, error) {
+	var dates []time.Time
+	err := db.QueryForkDates(owner, name, &dates)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+	for _, fork := range fetched {
+		dates = append(dates, fork.CreatedAt)
+	}
+
+	forksPerMonth, err := stats.MonthlyGraph(dates)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+
+	forksCountLast12Months, forksCountLast4Weeks, forksCountLastWeek := countInActivityWindows(dates)
+	return forksCountLast12Months, forksCountLast4Weeks, forksCountLastWeek, forksPerMonth, nil
+}
@@ -2,97 +2,101 @@ package github
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/flaviocopes/gitometer/server/common"
 	"github.com/flaviocopes/gitometer/server/db"
+	"github.com/flaviocopes/gitometer/server/stats"
 	gogithub "github.com/google/go-github/github"
-	"github.com/jinzhu/now"
 	"golang.org/x/oauth2"
 )
 
+var httpClient *http.Client
 var clientV3 *gogithub.Client
+var clientV4 *graphQLClient
+var transport *rateLimitTransport
 
-func getClientV3() *gogithub.Client {
-	if clientV3 == nil {
+// getHTTPClient returns the authenticated HTTP client shared by the REST
+// and the GraphQL clients, so both go through the same rate limiting
+func getHTTPClient() (*http.Client, error) {
+	if httpClient == nil {
 		ctx := context.Background()
 		at := os.Getenv("GITOMETER_GITHUB_ACCESS_TOKEN")
 		if at == "" {
-			panic("You need to set the GitHub access token as GITOMETER_GITHUB_ACCESS_TOKEN environment variable")
+			return nil, fmt.Errorf("You need to set the GitHub access token as GITOMETER_GITHUB_ACCESS_TOKEN environment variable")
 		}
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: at},
 		)
 		tc := oauth2.NewClient(ctx, ts)
-		clientV3 = gogithub.NewClient(tc)
+		transport = newRateLimitTransport(tc.Transport)
+		tc.Transport = transport
+		httpClient = tc
 	}
 
-	return clientV3
+	return httpClient, nil
 }
 
-func getBasicRepoInfo(owner, name string) *common.Repository {
-	ctx := context.Background()
-	repo, _, err := getClientV3().Repositories.Get(ctx, owner, name)
-	if err != nil {
-		panic(err)
+func getClientV3() (*gogithub.Client, error) {
+	if clientV3 == nil {
+		tc, err := getHTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		clientV3 = gogithub.NewClient(tc)
 	}
 
-	r := common.Repository{}
-	r.ID = *repo.ID
-	r.Name = *repo.Name
-	r.OwnerName = owner
-	r.DefaultBranch = *repo.DefaultBranch
-	r.CreatedAt = (*repo.CreatedAt).Format(time.RFC3339)
-	r.Initialized = false
-	r.Description = *repo.Description
-	r.RepoAge = monthsCountSince((*repo.CreatedAt).Time)
-	r.TotalStars = *repo.StargazersCount
-	r.TotalCommits = getTotalCommits(owner, name)
-	r.CommitsCountLast12Months, r.CommitsCountLast4Weeks, r.CommitsCountLastWeek = getCommitsData(owner, name)
-	r.StarsCountLast12Months, r.StarsCountLast4Weeks, r.StarsCountLastWeek, r.StarsPerMonth = getStarsData(owner, name)
-
-	return &r
+	return clientV3, nil
 }
 
-func getCommitsData(owner, name string) (int, int, int) {
-	data, _, err := getClientV3().Repositories.ListParticipation(context.Background(), owner, name)
-	if err != nil {
-		log.Fatalf("Repositories.ListParticipation returned error: %v", err)
-	}
-	w := data.All
-	commitsCountLast12Months := 0
-	for _, v := range w {
-		commitsCountLast12Months += v
-	}
-	commitsCountLast4Weeks := 0
-	for _, v := range w[len(w)-4:] {
-		commitsCountLast4Weeks += v
+func getClientV4() (*graphQLClient, error) {
+	if clientV4 == nil {
+		tc, err := getHTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		clientV4 = newGraphQLClient(tc)
 	}
-	commitsCountLastWeek := w[len(w)-1]
-	return commitsCountLast12Months, commitsCountLast4Weeks, commitsCountLastWeek
+
+	return clientV4, nil
 }
 
-func getTotalCommits(owner, name string) int {
-	opt := &gogithub.CommitsListOptions{
-		ListOptions: gogithub.ListOptions{PerPage: 30},
+func getBasicRepoInfo(ctx context.Context, owner, name string) (*common.Repository, error) {
+	client, err := getClientV3()
+	if err != nil {
+		return nil, err
 	}
-	_, resp, err := getClientV3().Repositories.ListCommits(context.Background(), owner, name, opt)
+	repo, _, err := client.Repositories.Get(ctx, owner, name)
 	if err != nil {
-		log.Fatalf("Repositories.ListCommits returned error: %v", err)
+		return nil, wrapError(err)
 	}
-	pagesCount := resp.LastPage
-	tot := (pagesCount - 1) * 30 // 30 items per page until the last one
-	opt.Page = resp.LastPage
-	commits, _, err := getClientV3().Repositories.ListCommits(context.Background(), owner, name, opt)
+
+	r := common.Repository{}
+	r.ID = int(repo.GetID())
+	r.Name = repo.GetName()
+	r.OwnerName = repo.GetOwner().GetLogin()
+	r.DefaultBranch = repo.GetDefaultBranch()
+	r.CreatedAt = repo.GetCreatedAt().Format(time.RFC3339)
+	r.Initialized = false
+	r.Description = repo.GetDescription()
+	r.RepoAge = monthsCountSince(repo.GetCreatedAt().Time)
+	r.Fork = repo.GetFork()
+
+	counts, err := getRepoCounts(ctx, r.OwnerName, r.Name)
 	if err != nil {
-		log.Fatalf("Repositories.ListCommits returned error: %v", err)
+		return nil, err
 	}
-	tot = tot + len(commits) //the last page contains the ramaining few commits, <= 30
-	return tot
+	r.TotalStars = counts.Stars
+	r.TotalForks = counts.Forks
+	r.TotalCommits = counts.Commits
+	r.TotalIssuesOpened = counts.Issues
+	r.TotalPullRequests = counts.PullRequests
+
+	return &r, nil
 }
 
 // monthsCountSince calculates the months between now
@@ -113,262 +117,277 @@ func monthsCountSince(createdAtTime time.Time) int {
 	return months
 }
 
-// AddRepoToDb adds a repository to the database. It's the only
-// exported method of this package.
-func AddRepoToDb(owner, name string) {
-	repo := getBasicRepoInfo(owner, name)
-	err := db.AddNewRepo(owner, name, repo)
-	if err != nil {
-		panic(err)
-	}
-}
-
-type yearmonth struct{ Year, Month int }
-type yearweek struct{ Year, Week int }
-
-func getStarsData(owner, name string) (int, int, int, string) {
-
-	dateTimeNow := time.Now()
-	dateTimeLastWeek := dateTimeNow.AddDate(0, 0, -7)
-
-	startWeek := now.New(dateTimeLastWeek).BeginningOfWeek()
-	endWeek := now.New(dateTimeLastWeek).EndOfWeek()
+// The phases of an import that report their progress
+const (
+	PhaseStargazers = "stargazers"
+	PhaseUnstars    = "unstars"
+	PhaseCommits    = "commits"
+)
 
-	lastSundayDate := time.Time(endWeek)
-	dateStartLastWeek := time.Time(startWeek)
+// Progress is called by the ingestion pipeline every time a page of
+// data is fetched from GitHub, with the phase of the import, the number
+// of pages of the phase fetched so far and the number of pages the phase
+// is expected to need
+type Progress func(phase string, fetched, total int)
+
+// AddRepoToDb adds a repository to the database, reporting how the
+// import is going to `progress` if not nil. The GitHub calls stop and
+// nothing is stored once `ctx` is done
+func AddRepoToDb(ctx context.Context, owner, name string, progress Progress) error {
+	return syncRepo(ctx, owner, name, false, progress)
+}
 
-	dateStartLast4Weeks := startWeek.AddDate(0, 0, -7*3)
-	dateStartLast12Months := startWeek.AddDate(0, 0, -7*51)
+// RefreshRepo updates a repository already in the database like
+// AddRepoToDb does, and fails with common.ErrRepoNotFound instead of
+// adding it again if it was deleted in the meantime
+func RefreshRepo(ctx context.Context, owner, name string, progress Progress) error {
+	return syncRepo(ctx, owner, name, true, progress)
+}
 
-	opt := gogithub.ListOptions{PerPage: 10}
-	stargazers, resp, err := clientV3.Activity.ListStargazers(context.Background(), owner, name, &opt)
+func syncRepo(ctx context.Context, owner, name string, refresh bool, progress Progress) error {
+	repo, err := getBasicRepoInfo(ctx, owner, name)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	var stars []time.Time
-	for _, v := range stargazers {
-		stars = append(stars, v.StarredAt.Time)
+	// use the names as GitHub spells them, whatever the case they were
+	// requested with
+	owner, name = repo.OwnerName, repo.Name
+	stargazers, err := fetchNewStargazers(ctx, owner, name, progress)
+	if err != nil {
+		return err
 	}
-
-	stars = reverseStarsSlice(stars)
-	results := stars
-
-	continueFlag := true
-
-	if resp.LastPage != resp.FirstPage { //more than one page
-		opt.Page = resp.LastPage
-		stargazers, resp, err := clientV3.Activity.ListStargazers(context.Background(), owner, name, &opt)
+	newUnstars, unstarsCheckedAt, err := detectUnstars(ctx, owner, name, repo.TotalStars, stargazers, progress)
+	if err != nil {
+		return err
+	}
+	var unstars []common.Unstar
+	err = db.QueryUnstars(owner, name, &unstars)
+	if err != nil {
+		return err
+	}
+	unstars = append(unstars, newUnstars...)
+	repo.StarsCountLast12Months, repo.StarsCountLast4Weeks, repo.StarsCountLastWeek, err = getStarsData(owner, name, stargazers)
+	if err != nil {
+		return err
+	}
+	repo.UnstarsCountLast12Months, repo.UnstarsCountLast4Weeks, repo.UnstarsCountLastWeek = getUnstarsData(unstars)
+	forks, err := fetchNewForks(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	repo.ForksCountLast12Months, repo.ForksCountLast4Weeks, repo.ForksCountLastWeek, repo.ForksPerMonth, err = getForksData(owner, name, forks)
+	if err != nil {
+		return err
+	}
+	commits, err := fetchNewCommits(ctx, owner, name, repo.DefaultBranch, progress)
+	if err != nil {
+		return err
+	}
+	repo.CommitsCountLast12Months, repo.CommitsCountLast4Weeks, repo.CommitsCountLastWeek, repo.CommitsPerMonth, err = getCommitsData(owner, name, commits)
+	if err != nil {
+		return err
+	}
+	// canceled after the last call, the repository may have been deleted
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+	if refresh {
+		err = db.UpdateRepo(owner, name, repo)
+	} else {
+		err = db.AddNewRepo(owner, name, repo)
+	}
+	if err != nil {
+		return err
+	}
+	// stored once the repository row exists, so a failure here only
+	// means the next refresh fetches them again
+	err = db.AddStargazers(owner, name, stargazers)
+	if err != nil {
+		return err
+	}
+	err = db.AddUnstars(owner, name, newUnstars)
+	if err != nil {
+		return err
+	}
+	if !unstarsCheckedAt.IsZero() {
+		err = db.SetUnstarsChecked(owner, name, unstarsCheckedAt)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		for _, v := range stargazers {
-			stars = append(stars, v.StarredAt.Time)
-		}
-
-		stars = reverseStarsSlice(stars)
-		results = append(results, stars...)
-		prevPage := resp.PrevPage
-
-		for {
-			if !continueFlag {
-				break
-			}
-			if prevPage == 0 {
-				break
-			}
-
-			opt.Page = prevPage
-			fmt.Println(prevPage)
-
-			stargazers, resp, err := clientV3.Activity.ListStargazers(context.Background(), owner, name, &opt)
-			if err != nil {
-				panic(err)
-			}
+	}
+	err = storeStarBuckets(owner, name)
+	if err != nil {
+		return err
+	}
+	err = db.AddForks(owner, name, forks)
+	if err != nil {
+		return err
+	}
+	err = db.AddCommits(owner, name, commits)
+	if err != nil {
+		return err
+	}
+	err = storeSpikeEvents(owner, name)
+	if err != nil {
+		return err
+	}
+	err = syncIssues(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	err = syncContributors(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	err = syncReleases(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	return db.AddRepoSnapshot(owner, name, repo)
+}
 
-			var s []time.Time
-			for _, v := range stargazers {
-				s = append(s, v.StarredAt.Time)
-			}
+// CheckRepo verifies that the repository exists on GitHub and can be
+// read with the configured access token. It gives up once `ctx` is done,
+// the rate limit may hold the call until its reset
+func CheckRepo(ctx context.Context, owner, name string) error {
+	client, err := getClientV3()
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Repositories.Get(ctx, owner, name)
+	return wrapError(err)
+}
 
-			s = reverseStarsSlice(s)
-			results = append(results, s...)
-			time.Sleep(time.Second * 1)
+// ListAccountRepos lists the repositories of the organization `account`,
+// or of the user `account` when `org` is false. The private repositories
+// of an organization are listed when the access token can read them.
+// GitHub only lists the private repositories of a user to the user
+// themselves, so they are listed when `account` owns the access token.
+// Listing gives up once `ctx` is done
+func ListAccountRepos(ctx context.Context, account string, org bool) ([]common.AccountRepo, error) {
+	client, err := getClientV3()
+	if err != nil {
+		return nil, err
+	}
 
-			prevPage = resp.PrevPage
+	authenticated := false
+	if !org {
+		user, _, err := client.Users.Get(ctx, "")
+		if err != nil {
+			return nil, wrapError(err)
 		}
-
+		authenticated = strings.EqualFold(user.GetLogin(), account)
 	}
 
-	starsCountLastWeek := 0
-	starsCountLast4Weeks := 0
-	starsCountLast12Months := 0
-
-	weeklyData := make(map[yearweek]int)
-	starringData := make(map[yearmonth]int)
-	month := 0
-	year := 0
-
-	for _, result := range results {
-		starredAt := result
-		month = int(result.Month())
-		year = int(result.Year())
-
-		if _, ok := starringData[yearmonth{year, month}]; ok {
-			starringData[yearmonth{year, month}]++
+	var repos []common.AccountRepo
+	listOpt := gogithub.ListOptions{PerPage: 100}
+	for {
+		var page []*gogithub.Repository
+		var resp *gogithub.Response
+		if org {
+			opt := &gogithub.RepositoryListByOrgOptions{Type: "all", ListOptions: listOpt}
+			page, resp, err = client.Repositories.ListByOrg(ctx, account, opt)
+		} else if authenticated {
+			// an empty user lists the repositories of the authenticated
+			// user, private ones included
+			opt := &gogithub.RepositoryListOptions{Affiliation: "owner", ListOptions: listOpt}
+			page, resp, err = client.Repositories.List(ctx, "", opt)
 		} else {
-			starringData[yearmonth{year, month}] = 1
+			opt := &gogithub.RepositoryListOptions{Type: "owner", ListOptions: listOpt}
+			page, resp, err = client.Repositories.List(ctx, account, opt)
 		}
-
-		if starredAt.After(lastSundayDate) {
-			// drop newer stars
-			continue
+		err = wrapError(err)
+		if _, ok := err.(common.ErrUpstreamNotFound); ok {
+			return nil, common.ErrUpstreamNotFound("Account not found on GitHub")
 		}
-
-		if starredAt.After(dateStartLastWeek) {
-			starsCountLastWeek++
-		}
-		if starredAt.After(dateStartLast4Weeks) {
-			starsCountLast4Weeks++
+		if err != nil {
+			return nil, err
 		}
-		if starredAt.After(dateStartLast12Months) {
-			starsCountLast12Months++
+		for _, r := range page {
+			repos = append(repos, common.AccountRepo{
+				Owner:    r.GetOwner().GetLogin(),
+				Name:     r.GetName(),
+				Fork:     r.GetFork(),
+				Archived: r.GetArchived(),
+				Private:  r.GetPrivate(),
+			})
 		}
-
-		_, week := starredAt.ISOWeek()
-
-		if _, ok := weeklyData[yearweek{year, week}]; ok {
-			weeklyData[yearweek{year, week}]++
-		} else {
-			weeklyData[yearweek{year, week}] = 1
+		if resp.NextPage == 0 {
+			return repos, nil
 		}
+		listOpt.Page = resp.NextPage
 	}
-
-	starsPerMonth := prepareDataForGraph(starringData)
-
-	return starsCountLast12Months, starsCountLast4Weeks, starsCountLastWeek, starsPerMonth
 }
 
-func fillMissingMonths(data map[yearmonth]int) (map[yearmonth]int, int, int, int, int) {
-	keys := make([]yearmonth, 0, len(data))
-	for k := range data {
-		keys = append(keys, k)
-	}
-
-	var lowestYear, highestYear, lowestMonthInLowestYear, highestMonthInHighestYear int
-
-	for _, k := range keys {
-		if highestYear == 0 || k.Year >= highestYear {
-			highestYear = k.Year
-			if highestMonthInHighestYear == 0 || k.Month > highestMonthInHighestYear {
-				highestMonthInHighestYear = k.Month
-			}
-		}
-		if lowestYear == 0 || k.Year <= lowestYear {
-			lowestYear = k.Year
-			if lowestMonthInLowestYear == 0 || k.Month < lowestMonthInLowestYear {
-				lowestMonthInLowestYear = k.Month
-			}
-		}
-	}
-
-	for month := lowestMonthInLowestYear; month <= 12; month++ {
-		if _, ok := data[yearmonth{lowestYear, month}]; !ok {
-			data[yearmonth{lowestYear, month}] = 0
-		}
-	}
-
-	for year := lowestYear + 1; year < highestYear; year++ {
-		for month := 1; month <= 12; month++ {
-			if _, ok := data[yearmonth{year, month}]; !ok {
-				data[yearmonth{year, month}] = 0
-			}
-		}
+// RateLimit fills `status` with the remaining GitHub API budget. Asking
+// for it does not count against the budget
+func RateLimit(status *common.RateLimitStatus) error {
+	client, err := getClientV3()
+	if err != nil {
+		return err
 	}
-
-	for month := highestMonthInHighestYear; month > 0; month-- {
-		if _, ok := data[yearmonth{highestYear, month}]; !ok {
-			data[yearmonth{highestYear, month}] = 0
-		}
+	limits, _, err := client.RateLimits(context.Background())
+	if err != nil {
+		return wrapError(err)
 	}
-
-	return data, lowestYear, highestYear, lowestMonthInLowestYear, highestMonthInHighestYear
-}
-
-func generateLabelsForGraph(data map[yearmonth]int, lowestYear, highestYear, lowestMonthInLowestYear, highestMonthInHighestYear int) []string {
-	var labels []string
-
-	for month := lowestMonthInLowestYear; month <= 12; month++ {
-		if _, ok := data[yearmonth{lowestYear, month}]; ok {
-			labels = append(labels, fmt.Sprintf("%d %d", month, lowestYear))
+	if limits.Core != nil {
+		status.Core = common.RateLimitBudget{
+			Limit:     limits.Core.Limit,
+			Remaining: limits.Core.Remaining,
+			Reset:     limits.Core.Reset.Time,
 		}
 	}
-
-	for year := lowestYear + 1; year < highestYear; year++ {
-		for month := 1; month <= 12; month++ {
-			if _, ok := data[yearmonth{year, month}]; ok {
-				labels = append(labels, fmt.Sprintf("%d %d", month, year))
-			}
+	if limits.Search != nil {
+		status.Search = common.RateLimitBudget{
+			Limit:     limits.Search.Limit,
+			Remaining: limits.Search.Remaining,
+			Reset:     limits.Search.Reset.Time,
 		}
 	}
-
-	for month := 1; month <= highestMonthInHighestYear; month++ {
-		if _, ok := data[yearmonth{highestYear, month}]; ok {
-			labels = append(labels, fmt.Sprintf("%d %d", month, highestYear))
-		}
+	if pausedUntil := transport.PausedUntil(); !pausedUntil.IsZero() {
+		status.PausedUntil = &pausedUntil
 	}
-
-	return labels
+	return nil
 }
 
-func generateDataForGraph(data map[yearmonth]int, lowestYear, highestYear, lowestMonthInLowestYear, highestMonthInHighestYear int) []int {
-	var commitsCount []int
-	total := 0
-
-	year := lowestYear
-	for month := lowestMonthInLowestYear; month <= 12; month++ {
-		total += data[yearmonth{lowestYear, month}]
-		commitsCount = append(commitsCount, total)
-	}
-
-	for year = lowestYear + 1; year < highestYear; year++ {
-		for month := 1; month <= 12; month++ {
-			total += data[yearmonth{year, month}]
-			commitsCount = append(commitsCount, total)
-		}
+// getStarsData computes the stars counters from the stars stored for
+// the repository, plus the `fetched` ones not stored yet
+func getStarsData(owner, name string, fetched []common.Stargazer) (int, int, int, error) {
+	var results []time.Time
+	err := db.QueryStarDates(owner, name, &results)
+	if err != nil {
+		return 0, 0, 0, err
 	}
-
-	year = highestYear
-	for month := highestMonthInHighestYear; month > 0; month-- {
-		total += data[yearmonth{year, month}]
-		commitsCount = append(commitsCount, total)
+	for _, stargazer := range fetched {
+		results = append(results, stargazer.StarredAt)
 	}
 
-	return commitsCount
+	starsCountLast12Months, starsCountLast4Weeks, starsCountLastWeek := countInActivityWindows(results)
+	return starsCountLast12Months, starsCountLast4Weeks, starsCountLastWeek, nil
 }
 
-func prepareDataForGraph(data map[yearmonth]int) string {
-	preparedData, lowestYear, highestYear, lowestMonthInLowestYear, highestMonthInHighestYear := fillMissingMonths(data)
-
-	type dataForGraph struct {
-		Labels []string `json:"labels"`
-		Data   []int    `json:"data"`
+// getUnstarsData counts the unstars detected in the activity windows
+func getUnstarsData(unstars []common.Unstar) (int, int, int) {
+	dates := make([]time.Time, 0, len(unstars))
+	for _, unstar := range unstars {
+		dates = append(dates, unstar.UnstarredAt)
 	}
-
-	graphLabels := generateLabelsForGraph(preparedData, lowestYear, highestYear, lowestMonthInLowestYear, highestMonthInHighestYear)
-	graphData := generateDataForGraph(preparedData, lowestYear, highestYear, lowestMonthInLowestYear, highestMonthInHighestYear)
-
-	c, err := json.Marshal(dataForGraph{graphLabels, graphData})
-	if err != nil {
-		panic(err)
-	}
-
-	return string(c)
+	return countInActivityWindows(dates)
 }
 
-func reverseStarsSlice(s []time.Time) []time.Time {
-	var n []time.Time
-	for i := len(s) - 1; i >= 0; i-- {
-		n = append(n, s[i])
+// countInActivityWindows counts the dates falling in the windows of the
+// activity counters: the last 12 months, the last 4 weeks and the last
+// week, as stats.CounterWindows defines them
+func countInActivityWindows(dates []time.Time) (int, int, int) {
+	windows := stats.CounterWindows(time.Now())
+	counts := make([]int, len(windows))
+	for _, date := range dates {
+		for i, window := range windows {
+			if window.Contains(date) {
+				counts[i]++
+			}
+		}
 	}
-	return n
+	return counts[2], counts[1], counts[0]
 }
@@ -0,0 +1,166 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// graphQLEndpoint is where the GitHub API v4 answers
+const graphQLEndpoint = "https://api.github.com/graphql"
+
+// graphQLClient sends queries to the GitHub GraphQL API v4, which can
+// fetch in a single round trip what takes many REST calls
+type graphQLClient struct {
+	client   *http.Client
+	endpoint string
+}
+
+func newGraphQLClient(client *http.Client) *graphQLClient {
+	return &graphQLClient{client: client, endpoint: graphQLEndpoint}
+}
+
+type graphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Query runs `query` with `variables`, decoding the `data` of the
+// response into `result`
+func (c *graphQLClient) Query(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return wrapError(err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		return common.ErrRateLimited("GitHub rate limit exceeded, retry later")
+	case resp.StatusCode >= 500:
+		return common.ErrUpstreamUnavailable(fmt.Sprintf("GitHub is unavailable: %s", resp.Status))
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("GitHub GraphQL API answered %s", resp.Status)
+	}
+
+	var out struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return err
+	}
+	if len(out.Errors) > 0 {
+		messages := make([]string, 0, len(out.Errors))
+		for _, e := range out.Errors {
+			switch e.Type {
+			case "NOT_FOUND":
+				return common.ErrUpstreamNotFound("Repository not found on GitHub")
+			case "RATE_LIMITED":
+				return common.ErrRateLimited("GitHub rate limit exceeded, retry later")
+			}
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("GitHub GraphQL API error: %s", strings.Join(messages, "; "))
+	}
+	return json.Unmarshal(out.Data, result)
+}
+
+// repoCounts contains the totals of a repository as GitHub counts them
+type repoCounts struct {
+	Stars        int
+	Forks        int
+	Commits      int
+	Issues       int
+	PullRequests int
+}
+
+const repoCountsQuery = `
+query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    stargazers { totalCount }
+    forkCount
+    issues { totalCount }
+    pullRequests { totalCount }
+    defaultBranchRef {
+      target {
+        ... on Commit {
+          history { totalCount }
+        }
+      }
+    }
+  }
+}`
+
+// getRepoCounts fetches the stars, forks, issues and pull requests of the
+// repository, and the commits on its default branch, in a single request
+func getRepoCounts(ctx context.Context, owner, name string) (repoCounts, error) {
+	client, err := getClientV4()
+	if err != nil {
+		return repoCounts{}, err
+	}
+
+	var result struct {
+		Repository *struct {
+			Stargazers struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"stargazers"`
+			ForkCount int `json:"forkCount"`
+			Issues    struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"issues"`
+			PullRequests struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"pullRequests"`
+			DefaultBranchRef *struct {
+				Target struct {
+					History struct {
+						TotalCount int `json:"totalCount"`
+					} `json:"history"`
+				} `json:"target"`
+			} `json:"defaultBranchRef"`
+		} `json:"repository"`
+	}
+	err = client.Query(ctx, repoCountsQuery, map[string]interface{}{
+		"owner": owner,
+		"name":  name,
+	}, &result)
+	if err != nil {
+		return repoCounts{}, err
+	}
+	r := result.Repository
+	if r == nil {
+		return repoCounts{}, common.ErrUpstreamNotFound("Repository not found on GitHub")
+	}
+
+	counts := repoCounts{
+		Stars:        r.Stargazers.TotalCount,
+		Forks:        r.ForkCount,
+		Issues:       r.Issues.TotalCount,
+		PullRequests: r.PullRequests.TotalCount,
+	}
+	// empty repositories have no default branch
+	if r.DefaultBranchRef != nil {
+		counts.Commits = r.DefaultBranchRef.Target.History.TotalCount
+	}
+	return counts, nil
+}
@@ -0,0 +1,172 @@
+package github
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	gogithub "github.com/google/go-github/github"
+)
+
+// issuesPerPage is the largest page size GitHub allows
+const issuesPerPage = 100
+
+// syncIssues stores the issues and pull requests of the repository that
+// were updated since the last sync, with when the pull requests were
+// merged and when someone other than the author first commented them
+func syncIssues(ctx context.Context, owner, name string) error {
+	client, err := getClientV3()
+	if err != nil {
+		return err
+	}
+	since, err := db.IssuesSyncedAt(owner, name)
+	if err != nil {
+		return err
+	}
+	syncStart := time.Now()
+
+	opt := gogithub.IssueListByRepoOptions{
+		State:       "all",
+		Sort:        "updated",
+		Direction:   "asc",
+		Since:       since,
+		ListOptions: gogithub.ListOptions{PerPage: issuesPerPage},
+	}
+	for {
+		page, resp, err := client.Issues.ListByRepo(ctx, owner, name, &opt)
+		if err != nil {
+			return wrapError(err)
+		}
+		issues := make([]common.Issue, 0, len(page))
+		for _, v := range page {
+			issues = append(issues, newIssue(v))
+		}
+		err = db.AddIssues(owner, name, issues)
+		if err != nil {
+			return err
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	merges, err := fetchPullRequestMerges(ctx, client, owner, name, since)
+	if err != nil {
+		return err
+	}
+	err = db.AddPullRequestMerges(owner, name, merges)
+	if err != nil {
+		return err
+	}
+
+	comments, err := fetchIssueComments(ctx, client, owner, name, since)
+	if err != nil {
+		return err
+	}
+	err = db.AddIssueComments(owner, name, comments)
+	if err != nil {
+		return err
+	}
+
+	return db.SetIssuesSynced(owner, name, syncStart)
+}
+
+func newIssue(v *gogithub.Issue) common.Issue {
+	issue := common.Issue{
+		Number:      v.GetNumber(),
+		PullRequest: v.IsPullRequest(),
+		Title:       v.GetTitle(),
+		Author:      v.GetUser().GetLogin(),
+		State:       v.GetState(),
+		Labels:      []string{},
+		CreatedAt:   v.GetCreatedAt(),
+		UpdatedAt:   v.GetUpdatedAt(),
+		ClosedAt:    v.ClosedAt,
+	}
+	for _, label := range v.Labels {
+		issue.Labels = append(issue.Labels, label.GetName())
+	}
+	return issue
+}
+
+// fetchPullRequestMerges returns when the pull requests updated since
+// `since` were merged, keyed by number. The issues API doesn't tell
+// merged pull requests apart from closed ones
+func fetchPullRequestMerges(ctx context.Context, client *gogithub.Client, owner, name string, since time.Time) (map[int]time.Time, error) {
+	merges := make(map[int]time.Time)
+	opt := gogithub.PullRequestListOptions{
+		State:       "closed",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: gogithub.ListOptions{PerPage: issuesPerPage},
+	}
+	for {
+		pulls, resp, err := client.PullRequests.List(ctx, owner, name, &opt)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		for _, v := range pulls {
+			if v.GetUpdatedAt().Before(since) {
+				// pull requests are sorted by last update, the rest
+				// were synced already
+				return merges, nil
+			}
+			if v.MergedAt != nil {
+				merges[v.GetNumber()] = *v.MergedAt
+			}
+		}
+		if resp.NextPage == 0 {
+			return merges, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// fetchIssueComments returns the comments posted on the issues and pull
+// requests of the repository since `since`
+func fetchIssueComments(ctx context.Context, client *gogithub.Client, owner, name string, since time.Time) ([]common.IssueComment, error) {
+	var comments []common.IssueComment
+	opt := gogithub.IssueListCommentsOptions{
+		Sort:        "created",
+		Direction:   "asc",
+		Since:       since,
+		ListOptions: gogithub.ListOptions{PerPage: issuesPerPage},
+	}
+	for {
+		// number 0 lists the comments of every issue of the repository
+		page, resp, err := client.Issues.ListComments(ctx, owner, name, 0, &opt)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		for _, v := range page {
+			number, ok := issueNumberFromURL(v.GetIssueURL())
+			if !ok {
+				continue
+			}
+			comments = append(comments, common.IssueComment{
+				Number:    number,
+				Author:    v.GetUser().GetLogin(),
+				CreatedAt: v.GetCreatedAt(),
+			})
+		}
+		if resp.NextPage == 0 {
+			return comments, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// issueNumberFromURL extracts the number from an issue API URL, like
+// https://api.github.com/repos/owner/name/issues/1347
+func issueNumberFromURL(url string) (int, bool) {
+	i := strings.LastIndex(url, "/")
+	if i == -1 {
+		return 0, false
+	}
+	number, err := strconv.Atoi(url[i+1:])
+	return number, err == nil
+}
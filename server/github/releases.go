@@ -0,0 +1,71 @@
+package github
+
+import (
+	"context"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	gogithub "github.com/google/go-github/github"
+)
+
+// syncReleases stores the published releases of the repository, with the
+// downloads of their assets, and the tags no release was published for.
+// Both lists are short, so they are fetched whole at every refresh to
+// pick up the download counts
+func syncReleases(ctx context.Context, owner, name string) error {
+	client, err := getClientV3()
+	if err != nil {
+		return err
+	}
+
+	var releases []common.Release
+	released := make(map[string]bool)
+	opt := &gogithub.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Repositories.ListReleases(ctx, owner, name, opt)
+		if err != nil {
+			return wrapError(err)
+		}
+		for _, r := range page {
+			if r.GetDraft() || r.PublishedAt == nil {
+				continue
+			}
+			publishedAt := r.GetPublishedAt().Time
+			release := common.Release{
+				Tag:         r.GetTagName(),
+				Name:        r.GetName(),
+				PublishedAt: &publishedAt,
+				Prerelease:  r.GetPrerelease(),
+			}
+			for _, asset := range r.Assets {
+				release.Downloads += asset.GetDownloadCount()
+			}
+			releases = append(releases, release)
+			released[release.Tag] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	opt = &gogithub.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Repositories.ListTags(ctx, owner, name, opt)
+		if err != nil {
+			return wrapError(err)
+		}
+		for _, t := range page {
+			if released[t.GetName()] {
+				continue
+			}
+			releases = append(releases, common.Release{Tag: t.GetName()})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return db.AddReleases(owner, name, releases)
+}
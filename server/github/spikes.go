@@ -0,0 +1,26 @@
+package github
+
+import (
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/db"
+	"github.com/flaviocopes/gitometer/server/stats"
+)
+
+// storeSpikeEvents looks for spikes in the stored stars and commits of
+// the repository, and stores them in place of the ones found before
+func storeSpikeEvents(owner, name string) error {
+	var stars []time.Time
+	err := db.QueryStarDates(owner, name, &stars)
+	if err != nil {
+		return err
+	}
+	var commits []time.Time
+	err = db.QueryCommitDates(owner, name, &commits)
+	if err != nil {
+		return err
+	}
+
+	events := append(stats.Spikes("stars", stars), stats.Spikes("commits", commits)...)
+	return db.ReplaceSpikeEvents(owner, name, events)
+}
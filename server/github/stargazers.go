@@ -0,0 +1,184 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	"github.com/flaviocopes/gitometer/server/stats"
+	gogithub "github.com/google/go-github/github"
+)
+
+// stargazersPerPage is the largest page size GitHub allows
+const stargazersPerPage = 100
+
+// fetchNewStargazers returns the stargazers who starred the repository
+// after the most recent one stored. GitHub lists stargazers oldest
+// first, so pages are walked from the last one back until a page
+// reaches the stored stars, which keeps refreshes down to a few requests
+func fetchNewStargazers(ctx context.Context, owner, name string, progress Progress) ([]common.Stargazer, error) {
+	client, err := getClientV3()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := db.LatestStarredAt(owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := gogithub.ListOptions{PerPage: stargazersPerPage}
+	firstPage, resp, err := client.Activity.ListStargazers(ctx, owner, name, &opt)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	pagesTotal := resp.LastPage
+	if pagesTotal == 0 {
+		pagesTotal = 1
+	}
+	pagesFetched := 1
+	if progress != nil {
+		progress(PhaseStargazers, pagesFetched, pagesTotal)
+	}
+
+	var results []common.Stargazer
+	for page := pagesTotal; page >= 1; page-- {
+		stargazers := firstPage
+		if page != 1 {
+			opt.Page = page
+			stargazers, _, err = client.Activity.ListStargazers(ctx, owner, name, &opt)
+			if err != nil {
+				return nil, wrapError(err)
+			}
+			pagesFetched++
+			if progress != nil {
+				progress(PhaseStargazers, pagesFetched, pagesTotal)
+			}
+		}
+
+		reachedStored := false
+		for _, v := range stargazers {
+			starredAt := v.GetStarredAt().Time
+			if !starredAt.After(latest) {
+				reachedStored = true
+				continue
+			}
+			results = append(results, common.Stargazer{
+				Login:     v.GetUser().GetLogin(),
+				StarredAt: starredAt,
+			})
+		}
+		if reachedStored {
+			break
+		}
+	}
+
+	if progress != nil && pagesFetched < pagesTotal {
+		// the remaining pages were already stored
+		progress(PhaseStargazers, pagesTotal, pagesTotal)
+	}
+
+	return results, nil
+}
+
+// unstarsCheckInterval bounds how long an unstar can go unnoticed
+const unstarsCheckInterval = 7 * 24 * time.Hour
+
+// detectUnstars compares the stargazers stored for the repository with
+// the ones GitHub currently lists, and returns the users who unstarred
+// it since the last comparison, along with the time of the comparison.
+// Listing every stargazer is expensive, so the comparison happens when
+// the stars counted by GitHub are fewer than the stargazers gitometer
+// knows about, and otherwise once every `unstarsCheckInterval`, as an
+// unstar and a new star between two refreshes leave the count unchanged.
+// Such unstars are recorded with the time of the comparison that
+// detected them. The returned time is zero when no comparison was made
+func detectUnstars(ctx context.Context, owner, name string, totalStars int, fetched []common.Stargazer, progress Progress) ([]common.Unstar, time.Time, error) {
+	var stored []common.Stargazer
+	err := db.QueryStargazers(owner, name, &stored)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(stored) == 0 {
+		// the fetched stargazers are all of them
+		return nil, time.Now(), nil
+	}
+	checkedAt, err := db.UnstarsCheckedAt(owner, name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(stored)+len(fetched) <= totalStars && time.Since(checkedAt) < unstarsCheckInterval {
+		return nil, time.Time{}, nil
+	}
+
+	client, err := getClientV3()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	current := make(map[string]bool)
+	opt := gogithub.ListOptions{PerPage: stargazersPerPage, Page: 1}
+	for {
+		stargazers, resp, err := client.Activity.ListStargazers(ctx, owner, name, &opt)
+		if err != nil {
+			return nil, time.Time{}, wrapError(err)
+		}
+		for _, v := range stargazers {
+			current[v.GetUser().GetLogin()] = true
+		}
+		if progress != nil {
+			pagesTotal := resp.LastPage
+			if pagesTotal < opt.Page {
+				pagesTotal = opt.Page
+			}
+			progress(PhaseUnstars, opt.Page, pagesTotal)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	detectedAt := time.Now()
+	var unstars []common.Unstar
+	for _, stargazer := range stored {
+		if !current[stargazer.Login] {
+			unstars = append(unstars, common.Unstar{
+				Login:       stargazer.Login,
+				StarredAt:   stargazer.StarredAt,
+				UnstarredAt: detectedAt,
+			})
+		}
+	}
+	return unstars, detectedAt, nil
+}
+
+// storedBuckets are the granularities the star buckets are stored for,
+// the other ones are computed when asked
+var storedBuckets = []string{stats.Week, stats.Month}
+
+// storeStarBuckets computes the star buckets of the repository from its
+// stored stars and unstars, and stores them
+func storeStarBuckets(owner, name string) error {
+	var stars []time.Time
+	err := db.QueryStarDates(owner, name, &stars)
+	if err != nil {
+		return err
+	}
+	var unstars []common.Unstar
+	err = db.QueryUnstars(owner, name, &unstars)
+	if err != nil {
+		return err
+	}
+	unstarDates := make([]time.Time, 0, len(unstars))
+	for _, unstar := range unstars {
+		unstarDates = append(unstarDates, unstar.UnstarredAt)
+	}
+
+	for _, granularity := range storedBuckets {
+		err = db.ReplaceStarBuckets(owner, name, granularity, stats.StarBuckets(stars, unstarDates, granularity))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package github
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetries is how many times a request is retried after an abuse
+	// rate limit response or a server error
+	maxRetries = 5
+
+	// baseBackoff is the wait before the first retry, doubled at every
+	// following attempt
+	baseBackoff = time.Second
+)
+
+// rateLimitTransport wraps the transport of the GitHub client, pausing
+// the requests until the rate limit resets when the budget is exhausted,
+// and retrying with exponential backoff on abuse rate limits and 5xx
+type rateLimitTransport struct {
+	base http.RoundTripper
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	free := isRateLimitRequest(req)
+	backoff := baseBackoff
+	for attempt := 0; ; attempt++ {
+		if !free {
+			if err := t.waitUntil(req, t.paused()); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		exhausted, reset := budgetExhausted(resp)
+		if exhausted {
+			// the response is good, the requests following it wait until
+			// the budget is back
+			t.pause(reset)
+		}
+
+		retry, wait := shouldRetry(resp, exhausted)
+		if !retry || attempt == maxRetries || !replayable(req) || (exhausted && free) {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		switch {
+		case exhausted:
+			// already paused until the reset
+		case resp.StatusCode >= 500:
+			if err := t.waitUntil(req, time.Now().Add(wait)); err != nil {
+				return nil, err
+			}
+		default:
+			// abuse limits apply to every request made with the token
+			t.pause(time.Now().Add(wait))
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// PausedUntil returns the time requests are held until, or a zero
+// time.Time if requests are going through
+func (t *rateLimitTransport) PausedUntil() time.Time {
+	p := t.paused()
+	if p.Before(time.Now()) {
+		return time.Time{}
+	}
+	return p
+}
+
+func (t *rateLimitTransport) paused() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pausedUntil
+}
+
+func (t *rateLimitTransport) pause(until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+// waitUntil sleeps until `until`, or until the request is canceled
+func (t *rateLimitTransport) waitUntil(req *http.Request, until time.Time) error {
+	d := time.Until(until)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// budgetExhausted reports whether the response used the last request
+// of the rate limit budget, and when the budget resets
+func budgetExhausted(resp *http.Response) (bool, time.Time) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return false, time.Time{}
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return false, time.Time{}
+	}
+	// GitHub and local clocks can disagree by a bit
+	return true, time.Unix(reset, 0).Add(time.Second)
+}
+
+// shouldRetry reports whether the request should be sent again, and how
+// long GitHub asked to wait before doing so. A zero wait means the
+// exponential backoff applies
+func shouldRetry(resp *http.Response, exhausted bool) (bool, time.Duration) {
+	switch {
+	case resp.StatusCode >= 500:
+		return true, 0
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		if exhausted {
+			return true, 0
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return true, time.Duration(seconds) * time.Second
+		}
+		return isAbuseResponse(resp), 0
+	}
+	return false, 0
+}
+
+// isAbuseResponse looks for the secondary rate limit documentation in
+// the body of a 403, leaving the body readable for the caller
+func isAbuseResponse(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	text := strings.ToLower(string(body))
+	return strings.Contains(text, "abuse") || strings.Contains(text, "secondary rate limit")
+}
+
+// isRateLimitRequest reports whether the request asks for the rate limit
+// status, which does not count against the budget and so never waits
+func isRateLimitRequest(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/rate_limit")
+}
+
+// replayable reports whether the request can be sent again
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
@@ -0,0 +1,127 @@
+package github
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// get sends a GET through the transport, with a context canceled after
+// `timeout`
+func get(t *testing.T, transport *rateLimitTransport, url string, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err == nil {
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRoundTripExhaustedBudget(t *testing.T) {
+	reset := time.Now().Add(3 * time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	transport := newRateLimitTransport(nil)
+
+	start := time.Now()
+	resp, err := get(t, transport, server.URL+"/repos/o/n", 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("the response using the last request took %v, want it right away", elapsed)
+	}
+	if transport.PausedUntil().IsZero() {
+		t.Fatal("PausedUntil is zero after the budget was exhausted")
+	}
+
+	_, err = get(t, transport, server.URL+"/repos/o/n", 200*time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("the request following the exhaustion returned %v, want it to wait", err)
+	}
+
+	start = time.Now()
+	_, err = get(t, transport, server.URL+"/rate_limit", 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("the rate limit request took %v, want it to skip the pause", elapsed)
+	}
+}
+
+func TestRoundTripRetries(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"abuse", http.StatusForbidden, `{"message":"You have triggered an abuse detection mechanism"}`},
+		{"secondary rate limit", http.StatusForbidden, `{"message":"You have exceeded a secondary rate limit"}`},
+		{"server error", http.StatusBadGateway, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.WriteHeader(test.status)
+					w.Write([]byte(test.body))
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			resp, err := get(t, newRateLimitTransport(nil), server.URL+"/repos/o/n", 10*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got status %d, want 200 after a retry", resp.StatusCode)
+			}
+			if n := atomic.LoadInt32(&calls); n != 2 {
+				t.Fatalf("got %d requests, want 2", n)
+			}
+		})
+	}
+}
+
+func TestRoundTripForbiddenIsNotRetried(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+	}))
+	defer server.Close()
+
+	resp, err := get(t, newRateLimitTransport(nil), server.URL+"/repos/o/n", 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("got status %d, want 403", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	"github.com/flaviocopes/gitometer/server/github"
+	"github.com/flaviocopes/gitometer/server/jobs"
+)
+
+// orgImportHandler imports the repositories of an organization, on
+// `POST /api/orgs/{org}/import`
+func orgImportHandler(w http.ResponseWriter, req *http.Request) {
+	setupResponse(&w, req)
+	if (*req).Method == "OPTIONS" {
+		return
+	}
+	handleImportAccount(w, req, "/api/orgs/", true)
+}
+
+// userImportHandler imports the repositories of a user, on
+// `POST /api/users/{user}/import`. Private repositories can only be
+// imported by the user owning the access token
+func userImportHandler(w http.ResponseWriter, req *http.Request) {
+	setupResponse(&w, req)
+	if (*req).Method == "OPTIONS" {
+		return
+	}
+	handleImportAccount(w, req, "/api/users/", false)
+}
+
+// handleImportAccount lists the repositories of the account and queues
+// an import for each one passing the filters of the optional JSON body.
+// Repositories already tracked are skipped, refreshes take care of them
+func handleImportAccount(w http.ResponseWriter, req *http.Request, prefix string, org bool) {
+	if req.Method != "POST" {
+		writeError(w, common.ErrMethodNotAllowed("Method not allowed"))
+		return
+	}
+	params, err := parseParams(req, prefix, 2)
+	if err != nil {
+		writeError(w, common.ErrBadRequest(err.Error()))
+		return
+	}
+	if params[1] != "import" {
+		writeError(w, common.ErrBadRequest("Bad format. Expecting "+prefix+"{account}/import"))
+		return
+	}
+
+	var opt common.ImportOptions
+	err = json.NewDecoder(req.Body).Decode(&opt)
+	if err != nil && err != io.EOF {
+		writeError(w, common.ErrBadRequest("Invalid JSON body: "+err.Error()))
+		return
+	}
+	var pattern *regexp.Regexp
+	if opt.Name != "" {
+		pattern, err = regexp.Compile(opt.Name)
+		if err != nil {
+			writeError(w, common.ErrBadRequest("Invalid name pattern: "+err.Error()))
+			return
+		}
+	}
+
+	repos, err := github.ListAccountRepos(req.Context(), params[0], org)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result := common.ImportResult{
+		Added:   []common.Job{},
+		Skipped: []common.SkippedRepo{},
+	}
+	for _, repo := range repos {
+		reason := ""
+		switch {
+		case opt.SkipForks && repo.Fork:
+			reason = "fork"
+		case opt.SkipArchived && repo.Archived:
+			reason = "archived"
+		case !opt.IncludePrivate && repo.Private:
+			reason = "private"
+		case pattern != nil && !pattern.MatchString(repo.Name):
+			reason = "name does not match"
+		}
+		if reason == "" {
+			exists, err := db.RepoExists(repo.Owner, repo.Name)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if exists {
+				reason = "already tracked"
+			}
+		}
+		if reason != "" {
+			result.Skipped = append(result.Skipped, common.SkippedRepo{
+				Owner:  repo.Owner,
+				Name:   repo.Name,
+				Reason: reason,
+			})
+			continue
+		}
+
+		job, err := jobs.Enqueue(repo.Owner, repo.Name)
+		if err != nil {
+			// the queue is full, the remaining repositories can be
+			// imported by calling again
+			result.Skipped = append(result.Skipped, common.SkippedRepo{
+				Owner:  repo.Owner,
+				Name:   repo.Name,
+				Reason: err.Error(),
+			})
+			continue
+		}
+		result.Added = append(result.Added, job)
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(out)
+}
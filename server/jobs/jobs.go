@@ -0,0 +1,234 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/github"
+)
+
+const (
+	importWorkers = "GITOMETER_IMPORT_WORKERS"
+
+	defaultWorkers = 2
+
+	// queueSize is the number of jobs that can wait for a worker
+	queueSize = 256
+
+	// retention is how long finished jobs can still be looked up
+	retention = 24 * time.Hour
+)
+
+var (
+	mu      sync.Mutex
+	jobs    map[string]*common.Job
+	done    map[string]chan struct{}
+	cancels map[string]context.CancelFunc
+	queue   chan string
+)
+
+// Start reads the number of workers from the environment and starts
+// the worker pool processing the import jobs
+func Start() {
+	if queue != nil {
+		return
+	}
+	workers := defaultWorkers
+	if value, ok := os.LookupEnv(importWorkers); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			panic("GITOMETER_IMPORT_WORKERS must be a positive number")
+		}
+		workers = n
+	}
+
+	jobs = make(map[string]*common.Job)
+	done = make(map[string]chan struct{})
+	cancels = make(map[string]context.CancelFunc)
+	queue = make(chan string, queueSize)
+	for i := 0; i < workers; i++ {
+		go work()
+	}
+}
+
+// Enqueue creates a job importing the repository `owner`/`name` and
+// returns a copy of it as it was queued. If the repository is already
+// queued or being imported, the existing job is returned instead
+func Enqueue(owner, name string) (common.Job, error) {
+	return enqueue(owner, name, false)
+}
+
+// EnqueueRefresh creates a job refreshing the repository `owner`/`name`
+// like Enqueue does. The job fails if the repository is not in the
+// database anymore, instead of adding it back
+func EnqueueRefresh(owner, name string) (common.Job, error) {
+	return enqueue(owner, name, true)
+}
+
+func enqueue(owner, name string, refresh bool) (common.Job, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	prune()
+
+	for _, job := range jobs {
+		if job.FinishedAt == nil && strings.EqualFold(job.Owner, owner) && strings.EqualFold(job.Name, name) {
+			return *job, nil
+		}
+	}
+
+	job := &common.Job{
+		ID:        newID(),
+		Owner:     owner,
+		Name:      name,
+		Refresh:   refresh,
+		State:     common.JobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	select {
+	case queue <- job.ID:
+	default:
+		return common.Job{}, common.ErrQueueFull("Too many imports queued, retry later")
+	}
+	jobs[job.ID] = job
+	done[job.ID] = make(chan struct{})
+
+	return *job, nil
+}
+
+// Get returns a copy of the job with the given id
+func Get(id string) (common.Job, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	job, ok := jobs[id]
+	if !ok {
+		return common.Job{}, common.ErrJobNotFound("Job not found")
+	}
+	return *job, nil
+}
+
+// Wait blocks until the job with the given id finished, or until `ctx`
+// is done, and returns a copy of the job
+func Wait(ctx context.Context, id string) (common.Job, error) {
+	mu.Lock()
+	ch, ok := done[id]
+	mu.Unlock()
+	if !ok {
+		return common.Job{}, common.ErrJobNotFound("Job not found")
+	}
+
+	select {
+	case <-ch:
+	case <-ctx.Done():
+		return common.Job{}, ctx.Err()
+	}
+	return Get(id)
+}
+
+// Cancel cancels the queued and running jobs of the repository
+// `owner`/`name`, and returns the ids of the running ones. Queued jobs
+// never run, and running jobs stop their GitHub calls and store nothing
+// more, as soon as Wait tells they finished
+func Cancel(owner, name string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var running []string
+	for id, job := range jobs {
+		if job.FinishedAt != nil || !strings.EqualFold(job.Owner, owner) || !strings.EqualFold(job.Name, name) {
+			continue
+		}
+		if job.State == common.JobRunning {
+			cancels[id]()
+			running = append(running, id)
+			continue
+		}
+		finishedAt := time.Now()
+		job.State = common.JobCanceled
+		job.FinishedAt = &finishedAt
+		close(done[id])
+	}
+	return running
+}
+
+func work() {
+	for id := range queue {
+		mu.Lock()
+		job := jobs[id]
+		if job.State == common.JobCanceled {
+			mu.Unlock()
+			continue
+		}
+		owner, name, refresh := job.Owner, job.Name, job.Refresh
+		startedAt := time.Now()
+		job.State = common.JobRunning
+		job.StartedAt = &startedAt
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[id] = cancel
+		mu.Unlock()
+
+		run := github.AddRepoToDb
+		if refresh {
+			run = github.RefreshRepo
+		}
+		err := run(ctx, owner, name, func(phase string, fetched, total int) {
+			mu.Lock()
+			job.Phase = phase
+			job.PagesFetched = fetched
+			job.PagesTotal = total
+			mu.Unlock()
+		})
+
+		mu.Lock()
+		finishedAt := time.Now()
+		job.FinishedAt = &finishedAt
+		switch {
+		case ctx.Err() != nil:
+			job.State = common.JobCanceled
+		case err != nil:
+			job.State = common.JobFailed
+			job.Error = err.Error()
+			job.Err = err
+		default:
+			job.State = common.JobDone
+		}
+		cancel()
+		delete(cancels, id)
+		close(done[id])
+		mu.Unlock()
+
+		if err != nil && ctx.Err() == nil {
+			log.Printf("jobs: import of %s/%s failed: %v", owner, name, err)
+		}
+	}
+}
+
+// prune forgets the jobs that finished more than `retention` ago.
+// Must be called with `mu` held
+func prune() {
+	limit := time.Now().Add(-retention)
+	for id, job := range jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(limit) {
+			delete(jobs, id)
+			delete(done, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
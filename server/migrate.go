@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/flaviocopes/gitometer/server/db"
+)
+
+// runMigrate implements the `migrate` subcommand:
+//
+//	migrate up            applies the pending migrations
+//	migrate down [steps]  reverts the last `steps` migrations (default 1)
+//	migrate status        lists the migrations and when they were applied
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: migrate up|down [steps]|status")
+	}
+	switch args[0] {
+	case "up":
+		return db.MigrateUp()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("Bad number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return db.MigrateDown(steps)
+	case "status":
+		migrations, err := db.Migrations()
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied at " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("Unknown migrate command %q, expecting up, down or status", args[0])
+	}
+}
@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+	"github.com/flaviocopes/gitometer/server/db"
+	"github.com/flaviocopes/gitometer/server/jobs"
+)
+
+const (
+	refreshInterval = "GITOMETER_REFRESH_INTERVAL"
+	refreshJitter   = "GITOMETER_REFRESH_JITTER"
+
+	defaultInterval = 24 * time.Hour
+	defaultJitter   = 30 * time.Minute
+
+	// tick is how often the scheduler looks for repositories due for
+	// a refresh
+	tick = time.Minute
+)
+
+type scheduler struct {
+	sync.Mutex
+	interval  time.Duration
+	jitter    time.Duration
+	startedAt time.Time
+	repos     map[string]*common.RefreshStatus
+}
+
+var s *scheduler
+
+// Start reads the scheduler configuration from the environment and
+// starts refreshing the enabled repositories in the background.
+// Setting GITOMETER_REFRESH_INTERVAL to 0 disables the scheduler
+func Start() {
+	if s != nil {
+		return
+	}
+	interval, jitter := schedulerConfig()
+	s = &scheduler{
+		interval: interval,
+		jitter:   jitter,
+		repos:    make(map[string]*common.RefreshStatus),
+	}
+	if interval == 0 {
+		fmt.Println("Scheduler disabled")
+		return
+	}
+	s.startedAt = time.Now()
+	go s.run()
+}
+
+// CurrentStatus fills `status` with the state of the scheduler and of
+// every repository it tracks
+func CurrentStatus(status *common.SchedulerStatus) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	status.Enabled = s.interval > 0
+	status.Interval = s.interval.String()
+	status.Jitter = s.jitter.String()
+	if !s.startedAt.IsZero() {
+		startedAt := s.startedAt
+		status.StartedAt = &startedAt
+	}
+	status.Repositories = []common.RefreshStatus{}
+	for _, repo := range s.repos {
+		status.Repositories = append(status.Repositories, *repo)
+	}
+	sort.Slice(status.Repositories, func(i, j int) bool {
+		a, b := status.Repositories[i], status.Repositories[j]
+		if a.OwnerName != b.OwnerName {
+			return a.OwnerName < b.OwnerName
+		}
+		return a.Name < b.Name
+	})
+}
+
+func schedulerConfig() (time.Duration, time.Duration) {
+	interval := defaultInterval
+	jitter := defaultJitter
+	if value, ok := os.LookupEnv(refreshInterval); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			panic("GITOMETER_REFRESH_INTERVAL must be a duration, e.g. 12h")
+		}
+		interval = d
+	}
+	if value, ok := os.LookupEnv(refreshJitter); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			panic("GITOMETER_REFRESH_JITTER must be a duration, e.g. 30m")
+		}
+		jitter = d
+	}
+	return interval, jitter
+}
+
+func (s *scheduler) run() {
+	for {
+		s.sync()
+		for _, repo := range s.due() {
+			s.refresh(repo)
+		}
+		time.Sleep(tick)
+	}
+}
+
+// sync reloads the list of enabled repositories, so repositories added
+// or disabled since the last tick are picked up
+func (s *scheduler) sync() {
+	var enabled []common.RefreshStatus
+	err := db.QueryEnabledRepos(&enabled)
+	if err != nil {
+		log.Printf("scheduler: cannot load repositories: %v", err)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	seen := make(map[string]bool)
+	for _, repo := range enabled {
+		key := repo.OwnerName + "/" + repo.Name
+		seen[key] = true
+		if _, ok := s.repos[key]; ok {
+			continue
+		}
+		next := time.Now().Add(s.randomJitter())
+		if repo.LastSuccessAt != nil {
+			next = repo.LastSuccessAt.Add(s.interval + s.randomJitter())
+		}
+		tracked := repo
+		tracked.NextRunAt = &next
+		s.repos[key] = &tracked
+	}
+	for key := range s.repos {
+		if !seen[key] {
+			delete(s.repos, key)
+		}
+	}
+}
+
+// due returns the keys of the repositories whose next run is in the past
+func (s *scheduler) due() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	var keys []string
+	now := time.Now()
+	for key, repo := range s.repos {
+		if repo.NextRunAt.Before(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (s *scheduler) refresh(key string) {
+	s.Lock()
+	repo, ok := s.repos[key]
+	if !ok {
+		s.Unlock()
+		return
+	}
+	repo.Running = true
+	owner, name := repo.OwnerName, repo.Name
+	s.Unlock()
+
+	err := runRefresh(owner, name)
+	now := time.Now()
+
+	s.Lock()
+	repo.Running = false
+	next := now.Add(s.interval + s.randomJitter())
+	repo.NextRunAt = &next
+	if err != nil {
+		repo.LastFailureAt = &now
+		repo.LastError = err.Error()
+	} else {
+		repo.LastSuccessAt = &now
+		repo.LastError = ""
+	}
+	s.Unlock()
+
+	if err != nil {
+		log.Printf("scheduler: refresh of %s failed: %v", key, err)
+		err = db.SetRefreshFailure(owner, name, now, err)
+	} else {
+		err = db.SetRefreshSuccess(owner, name, now)
+	}
+	if err != nil {
+		log.Printf("scheduler: cannot record refresh of %s: %v", key, err)
+	}
+}
+
+// runRefresh refreshes the repository through the import jobs, so it is
+// not refreshed twice at once when a refresh was also requested through
+// the API
+func runRefresh(owner, name string) error {
+	job, err := jobs.EnqueueRefresh(owner, name)
+	if err != nil {
+		return err
+	}
+	job, err = jobs.Wait(context.Background(), job.ID)
+	if err != nil {
+		return err
+	}
+	switch job.State {
+	case common.JobFailed:
+		return job.Err
+	case common.JobCanceled:
+		return common.ErrRepoNotFound("Repository deleted during the refresh")
+	}
+	return nil
+}
+
+func (s *scheduler) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.jitter)))
+}
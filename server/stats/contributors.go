@@ -0,0 +1,76 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// topContributors is how many contributors ContributorStats ranks
+const topContributors = 10
+
+// ContributorStats computes the contributors analytics of a repository
+// from the weekly commits of its contributors. A contributor is new in
+// the month of their first commit. Top contributors and bus factor
+// consider the commits authored since `since`
+func ContributorStats(total int, weeks []common.ContributorWeek, since time.Time) common.ContributorStats {
+	result := common.ContributorStats{
+		TotalContributors: total,
+		TopContributors:   []common.ContributorCommits{},
+	}
+
+	firstWeek := make(map[string]time.Time)
+	inWindow := make(map[string]int)
+	for _, week := range weeks {
+		if first, ok := firstWeek[week.Login]; !ok || week.Week.Before(first) {
+			firstWeek[week.Login] = week.Week
+		}
+		if !week.Week.Before(since) {
+			inWindow[week.Login] += week.Commits
+		}
+	}
+	firsts := make([]time.Time, 0, len(firstWeek))
+	for _, first := range firstWeek {
+		firsts = append(firsts, first)
+	}
+	result.NewContributorsPerMonth = PeriodCounts(firsts, Month)
+
+	ranked := make([]common.ContributorCommits, 0, len(inWindow))
+	for login, commits := range inWindow {
+		ranked = append(ranked, common.ContributorCommits{Login: login, Commits: commits})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Commits != ranked[j].Commits {
+			return ranked[i].Commits > ranked[j].Commits
+		}
+		return ranked[i].Login < ranked[j].Login
+	})
+	result.BusFactor = BusFactor(ranked)
+	if len(ranked) > topContributors {
+		ranked = ranked[:topContributors]
+	}
+	result.TopContributors = append(result.TopContributors, ranked...)
+	return result
+}
+
+// BusFactor returns the minimum number of contributors who authored at
+// least half of the commits. `ranked` must be sorted by commits, most
+// first
+func BusFactor(ranked []common.ContributorCommits) int {
+	total := 0
+	for _, c := range ranked {
+		total += c.Commits
+	}
+	if total == 0 {
+		return 0
+	}
+	covered := 0
+	for i, c := range ranked {
+		covered += c.Commits
+		if covered*2 >= total {
+			return i + 1
+		}
+	}
+	return len(ranked)
+}
@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+func TestBusFactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		commits []int
+		want    int
+	}{
+		{"no contributors", nil, 0},
+		{"no commits", []int{0, 0}, 0},
+		{"single contributor", []int{42}, 1},
+		{"one dominant contributor", []int{90, 5, 5}, 1},
+		{"exactly half", []int{50, 30, 20}, 1},
+		{"just under half", []int{49, 30, 21}, 2},
+		{"even split", []int{10, 10, 10, 10}, 2},
+		{"long tail", []int{20, 15, 10, 10, 10, 10, 10, 10, 5}, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ranked []common.ContributorCommits
+			for _, c := range test.commits {
+				ranked = append(ranked, common.ContributorCommits{Commits: c})
+			}
+			if got := BusFactor(ranked); got != test.want {
+				t.Fatalf("got bus factor %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// The models a counter can be forecast with
+const (
+	Linear      = "linear"
+	Exponential = "exponential"
+)
+
+const (
+	// minForecastMonths is how many complete months of history a
+	// forecast needs
+	minForecastMonths = 3
+
+	// maxHorizonMonths bounds how far in the future forecasts go
+	maxHorizonMonths = 120
+
+	// z95 is the quantile of the normal distribution for a 95% band
+	z95 = 1.96
+
+	// maxForecastGrowth is how many times the last value the band of a
+	// projection can reach before the model is deemed implausible, as the
+	// exponential model is on a history with spikes
+	maxForecastGrowth = 1000
+)
+
+// ParseHorizon returns the months of a horizon like `12m` or `2y`
+func ParseHorizon(horizon string) (int, error) {
+	bad := fmt.Errorf("Bad horizon %q. Expecting a number of months or years, e.g. 12m or 2y", horizon)
+	if len(horizon) < 2 {
+		return 0, bad
+	}
+	n, err := strconv.Atoi(horizon[:len(horizon)-1])
+	if err != nil || n < 1 {
+		return 0, bad
+	}
+	switch horizon[len(horizon)-1] {
+	case 'm':
+	case 'y':
+		n *= 12
+	default:
+		return 0, bad
+	}
+	if n > maxHorizonMonths {
+		return 0, fmt.Errorf("Horizon can be at most %d months", maxHorizonMonths)
+	}
+	return n, nil
+}
+
+// fit is a least squares line y = a + b*x, with what the prediction
+// intervals need
+type fit struct {
+	a, b       float64
+	sigma      float64
+	n          float64
+	meanX, sxx float64
+}
+
+func fitLine(xs, ys []float64) fit {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	f := fit{n: n, meanX: meanX, sxx: sxx}
+	if sxx > 0 {
+		f.b = sxy / sxx
+	}
+	f.a = meanY - f.b*meanX
+	var sse float64
+	for i := range xs {
+		r := ys[i] - f.predict(xs[i])
+		sse += r * r
+	}
+	if n > 2 {
+		f.sigma = math.Sqrt(sse / (n - 2))
+	}
+	return f
+}
+
+func (f fit) predict(x float64) float64 {
+	return f.a + f.b*x
+}
+
+// margin is the half width of the 95% prediction interval at `x`
+func (f fit) margin(x float64) float64 {
+	spread := 1 + 1/f.n
+	if f.sxx > 0 {
+		spread += (x - f.meanX) * (x - f.meanX) / f.sxx
+	}
+	return z95 * f.sigma * math.Sqrt(spread)
+}
+
+// Forecast fits a linear and an exponential model on `values`, the value
+// of a counter at the end of each month starting with the one of
+// `first`, and projects them `horizon` months past the last value. With
+// a positive `target`, each model also tells when it reaches it. The
+// exponential model is left out when its projection is not plausible
+func Forecast(first time.Time, values []float64, horizon int, target float64) ([]common.ForecastModel, error) {
+	if len(values) < minForecastMonths {
+		return nil, common.ErrBadRequest(fmt.Sprintf("Not enough history to forecast, %d complete months are needed", minForecastMonths))
+	}
+	xs := make([]float64, len(values))
+	for i := range values {
+		xs[i] = float64(i)
+	}
+	last := float64(len(values) - 1)
+
+	linear := fitLine(xs, values)
+	models := []common.ForecastModel{
+		project(Linear, linear, first, last, horizon, values, target, func(y float64) float64 { return y }),
+	}
+
+	// the exponential model is a line fitted on the logarithm of the
+	// values, the months before the first one are left out
+	var logXs, logYs []float64
+	for i, v := range values {
+		if v > 0 {
+			logXs = append(logXs, xs[i])
+			logYs = append(logYs, math.Log(v))
+		}
+	}
+	if len(logXs) >= minForecastMonths {
+		exponential := fitLine(logXs, logYs)
+		model := project(Exponential, exponential, first, last, horizon, values, target, math.Exp)
+		if plausible(model, values[len(values)-1]) {
+			models = append(models, model)
+		}
+	}
+	return models, nil
+}
+
+// plausible reports whether the band of every point of `model` stays
+// finite and within maxForecastGrowth times the `last` value
+func plausible(model common.ForecastModel, last float64) bool {
+	limit := maxForecastGrowth * math.Max(last, 1)
+	for _, p := range model.Points {
+		if math.IsNaN(p.Upper) || p.Upper > limit {
+			return false
+		}
+	}
+	return true
+}
+
+// project builds the forecast of a fitted model. `back` maps the values
+// of the fit to the ones of the counter
+func project(name string, f fit, first time.Time, last float64, horizon int, values []float64, target float64, back func(float64) float64) common.ForecastModel {
+	model := common.ForecastModel{
+		Model:  name,
+		Points: []common.ForecastPoint{},
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	var ssTot, ssRes float64
+	for i, v := range values {
+		predicted := back(f.predict(float64(i)))
+		ssTot += (v - mean) * (v - mean)
+		ssRes += (v - predicted) * (v - predicted)
+	}
+	if ssTot > 0 {
+		model.R2 = 1 - ssRes/ssTot
+	}
+
+	for m := 1; m <= horizon; m++ {
+		x := last + float64(m)
+		y, margin := f.predict(x), f.margin(x)
+		model.Points = append(model.Points, common.ForecastPoint{
+			Period: PeriodLabel(first.AddDate(0, int(x), 0), Month),
+			Value:  back(y),
+			Lower:  math.Max(0, back(y-margin)),
+			Upper:  back(y + margin),
+		})
+	}
+
+	if target > 0 && f.b > 0 {
+		// invert the model: the x at which it reaches the target
+		y := target
+		if name == Exponential {
+			y = math.Log(target)
+		}
+		x := (y - f.a) / f.b
+		if x > last {
+			date := monthEnd(first, x)
+			model.TargetDate = &date
+		}
+	}
+	return model
+}
+
+// monthEnd returns the time the month `x` months after the one of `first`
+// ends at, interpolating within the month for fractional values
+func monthEnd(first time.Time, x float64) time.Time {
+	whole := math.Floor(x + 1)
+	start := first.AddDate(0, int(whole), 0)
+	length := start.AddDate(0, 1, 0).Sub(start)
+	return start.Add(time.Duration((x + 1 - whole) * float64(length)))
+}
+
+// Best returns the model with the highest R2
+func Best(models []common.ForecastModel) string {
+	best := ""
+	bestR2 := math.Inf(-1)
+	for _, m := range models {
+		if m.R2 > bestR2 {
+			best, bestR2 = m.Model, m.R2
+		}
+	}
+	return best
+}
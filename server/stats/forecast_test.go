@@ -0,0 +1,205 @@
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+func TestFitLine(t *testing.T) {
+	f := fitLine([]float64{0, 1, 2, 3, 4}, []float64{2, 5, 8, 11, 14})
+	if f.a != 2 || f.b != 3 {
+		t.Fatalf("got y = %v + %v*x, want y = 2 + 3*x", f.a, f.b)
+	}
+	if f.sigma != 0 || f.margin(10) != 0 {
+		t.Fatalf("got sigma %v and margin %v for a perfect fit, want 0", f.sigma, f.margin(10))
+	}
+
+	f = fitLine([]float64{0, 1, 2, 3, 4, 5}, []float64{1, 3, 2, 5, 4, 6})
+	if f.sigma <= 0 {
+		t.Fatalf("got sigma %v for a noisy fit, want it positive", f.sigma)
+	}
+	// the band widens away from the middle of the history
+	if !(f.margin(f.meanX) < f.margin(6) && f.margin(6) < f.margin(12)) {
+		t.Fatalf("margins %v, %v, %v do not widen away from the history", f.margin(f.meanX), f.margin(6), f.margin(12))
+	}
+	want := z95 * f.sigma * math.Sqrt(1+1/f.n)
+	if math.Abs(f.margin(f.meanX)-want) > 1e-9 {
+		t.Fatalf("got margin %v at the mean, want %v", f.margin(f.meanX), want)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var linear, exponential []float64
+	for i := 0; i < 24; i++ {
+		linear = append(linear, 100+50*float64(i))
+		exponential = append(exponential, 10*math.Pow(1.2, float64(i)))
+	}
+
+	tests := []struct {
+		name   string
+		values []float64
+		best   string
+	}{
+		{"linear growth", linear, Linear},
+		{"exponential growth", exponential, Exponential},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			models, err := Forecast(first, test.values, 6, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(models) != 2 {
+				t.Fatalf("got %d models, want 2", len(models))
+			}
+			if best := Best(models); best != test.best {
+				t.Fatalf("got best model %s, want %s", best, test.best)
+			}
+			for _, m := range models {
+				if len(m.Points) != 6 {
+					t.Fatalf("%s: got %d points, want 6", m.Model, len(m.Points))
+				}
+				if m.Points[0].Period != "2022-01" || m.Points[5].Period != "2022-06" {
+					t.Fatalf("%s: got periods %s to %s, want 2022-01 to 2022-06", m.Model, m.Points[0].Period, m.Points[5].Period)
+				}
+				for _, p := range m.Points {
+					if p.Lower > p.Value || p.Value > p.Upper {
+						t.Fatalf("%s: %s value %v out of its band [%v, %v]", m.Model, p.Period, p.Value, p.Lower, p.Upper)
+					}
+				}
+				if m.TargetDate != nil {
+					t.Fatalf("%s: got a target date without a target", m.Model)
+				}
+			}
+		})
+	}
+
+	models, _ := Forecast(first, linear, 1, 0)
+	if got := models[0].Points[0].Value; math.Abs(got-1300) > 1e-6 {
+		t.Fatalf("got %v stars projected for 2022-01, want 1300", got)
+	}
+}
+
+func TestForecastTargetDate(t *testing.T) {
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var linear, doubling, shrinking []float64
+	for i := 0; i < 12; i++ {
+		linear = append(linear, 100+50*float64(i))
+		doubling = append(doubling, 10*math.Pow(2, float64(i)))
+		shrinking = append(shrinking, 1000-10*float64(i))
+	}
+
+	tests := []struct {
+		name   string
+		values []float64
+		target float64
+		model  string
+		want   *time.Time
+	}{
+		// 100 + 50*38 = 2000 at the end of the 39th month
+		{"linear", linear, 2000, Linear, date(2023, 4, 1)},
+		// halfway through the 40th month
+		{"linear mid month", linear, 2025, Linear, date(2023, 4, 16)},
+		// 10 * 2^14 = 163840 at the end of the 15th month
+		{"exponential", doubling, 163840, Exponential, date(2021, 4, 1)},
+		{"already reached", linear, 200, Linear, nil},
+		{"declining", shrinking, 2000, Linear, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			models, err := Forecast(first, test.values, 1, test.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var model common.ForecastModel
+			for _, m := range models {
+				if m.Model == test.model {
+					model = m
+				}
+			}
+			switch {
+			case test.want == nil && model.TargetDate != nil:
+				t.Fatalf("got target date %v, want none", model.TargetDate)
+			case test.want != nil && model.TargetDate == nil:
+				t.Fatalf("got no target date, want %v", test.want)
+			case test.want != nil && absDuration(model.TargetDate.Sub(*test.want)) > time.Minute:
+				t.Fatalf("got target date %v, want %v", model.TargetDate, test.want)
+			}
+		})
+	}
+}
+
+func TestForecastSpikyHistory(t *testing.T) {
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		values  []float64
+		horizon int
+	}{
+		{"infinite band", []float64{1, 40, 60000}, 120},
+		{"huge band", []float64{1, 5000, 40000, 42000}, 12},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			models, err := Forecast(first, test.values, test.horizon, 100000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(models) != 1 || models[0].Model != Linear {
+				t.Fatalf("got %d models, want the linear one only", len(models))
+			}
+			if _, err := json.Marshal(models); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestForecastNotEnoughHistory(t *testing.T) {
+	_, err := Forecast(time.Now(), []float64{1, 2}, 12, 0)
+	if _, ok := err.(common.ErrBadRequest); !ok {
+		t.Fatalf("got error %v, want a common.ErrBadRequest", err)
+	}
+}
+
+func TestParseHorizon(t *testing.T) {
+	tests := []struct {
+		horizon string
+		months  int
+		ok      bool
+	}{
+		{"12m", 12, true},
+		{"1m", 1, true},
+		{"2y", 24, true},
+		{"10y", 120, true},
+		{"121m", 0, false},
+		{"0m", 0, false},
+		{"12", 0, false},
+		{"12d", 0, false},
+		{"m", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		months, err := ParseHorizon(test.horizon)
+		if (err == nil) != test.ok || months != test.months {
+			t.Errorf("ParseHorizon(%q) = %d, %v, want %d and ok %v", test.horizon, months, err, test.months, test.ok)
+		}
+	}
+}
+
+func date(year int, month time.Month, day int) *time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
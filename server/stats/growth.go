@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// StarGrowth buckets the stars and the unstars of a repository by period,
+// from the period of the first star up to the current one, oldest first
+func StarGrowth(stars, unstars []time.Time, granularity string) []common.StarGrowth {
+	growth := []common.StarGrowth{}
+	if len(stars) == 0 {
+		return growth
+	}
+
+	first := stars[0]
+	for _, s := range stars {
+		if s.Before(first) {
+			first = s
+		}
+	}
+
+	gross := Count(stars, granularity)
+	lost := Count(unstars, granularity)
+	for _, p := range Periods(first, time.Now(), granularity) {
+		growth = append(growth, common.StarGrowth{
+			Period:  PeriodLabel(p, granularity),
+			Gross:   gross[p],
+			Unstars: lost[p],
+			Net:     gross[p] - lost[p],
+		})
+	}
+	return growth
+}
+
+// StarBuckets buckets the stars of a repository by period, net of the
+// unstars, with the stars it had at the end of each period. Periods go
+// from the one of the first star up to the current one, oldest first
+func StarBuckets(stars, unstars []time.Time, granularity string) []common.StarBucket {
+	buckets := []common.StarBucket{}
+	if len(stars) == 0 {
+		return buckets
+	}
+
+	first := stars[0]
+	for _, s := range stars {
+		if s.Before(first) {
+			first = s
+		}
+	}
+
+	gross := Count(stars, granularity)
+	lost := Count(unstars, granularity)
+	cumulative := 0
+	for _, p := range Periods(first, time.Now(), granularity) {
+		count := gross[p] - lost[p]
+		cumulative += count
+		buckets = append(buckets, common.StarBucket{
+			Period:     PeriodLabel(p, granularity),
+			Start:      p,
+			Count:      count,
+			Cumulative: cumulative,
+		})
+	}
+	return buckets
+}
+
+// StarsPerMonthGraph formats monthly star buckets as the deprecated
+// `stars_per_month` field: a JSON object with the months, labeled like
+// `1 2018`, and the stars the repository had at the end of each
+func StarsPerMonthGraph(buckets []common.StarBucket) (string, error) {
+	graph := struct {
+		Labels []string `json:"labels"`
+		Data   []int    `json:"data"`
+	}{[]string{}, []int{}}
+	for _, bucket := range buckets {
+		graph.Labels = append(graph.Labels, fmt.Sprintf("%d %d", bucket.Start.Month(), bucket.Start.Year()))
+		graph.Data = append(graph.Data, bucket.Cumulative)
+	}
+	out, err := json.Marshal(graph)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// MonthlyGraph formats the events of a repository as a graph shaped like
+// StarsPerMonthGraph: the months from the one of the first event up to
+// the current one, with the events up to the end of each
+func MonthlyGraph(events []time.Time) (string, error) {
+	return StarsPerMonthGraph(StarBuckets(events, nil, Month))
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMonthlyGraph(t *testing.T) {
+	month := PeriodStart(time.Now(), Month)
+	label := func(months int) string {
+		m := month.AddDate(0, months, 0)
+		return fmt.Sprintf("%d %d", m.Month(), m.Year())
+	}
+	var events []time.Time
+	events = append(events, month.AddDate(0, -14, 3))
+	for i := 0; i < 10; i++ {
+		events = append(events, month.AddDate(0, -12, i))
+	}
+	for i := 0; i < 100; i++ {
+		events = append(events, month.AddDate(0, -2, 0).Add(time.Duration(i)*time.Hour))
+	}
+
+	out, err := MonthlyGraph(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph struct {
+		Labels []string `json:"labels"`
+		Data   []int    `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(out), &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Labels) != 15 || len(graph.Data) != 15 {
+		t.Fatalf("got %d labels and %d values, want 15 months", len(graph.Labels), len(graph.Data))
+	}
+	seen := make(map[string]bool)
+	for i, l := range graph.Labels {
+		if want := label(i - 14); l != want {
+			t.Fatalf("got label %q at %d, want %q", l, i, want)
+		}
+		if seen[l] {
+			t.Fatalf("got label %q twice", l)
+		}
+		seen[l] = true
+	}
+	tests := []struct{ at, want int }{{0, 1}, {1, 1}, {2, 11}, {11, 11}, {12, 111}, {14, 111}}
+	for _, test := range tests {
+		if graph.Data[test.at] != test.want {
+			t.Fatalf("got %d events up to %s, want %d", graph.Data[test.at], graph.Labels[test.at], test.want)
+		}
+	}
+
+	out, err = MonthlyGraph(nil)
+	if err != nil || out != `{"labels":[],"data":[]}` {
+		t.Fatalf("got %s, %v for no events, want an empty graph", out, err)
+	}
+}
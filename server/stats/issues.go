@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// IssueStats computes the issues and pull requests analytics of a
+// repository. The issues opened are counted per month, from the month
+// of the first issue up to the current one
+func IssueStats(issues []common.Issue) common.IssueStats {
+	result := common.IssueStats{IssuesOpenedPerMonth: []common.PeriodCount{}}
+
+	var opened []time.Time
+	var toClose, toFirstResponse []float64
+	for _, issue := range issues {
+		closed := issue.State == "closed"
+		if issue.PullRequest {
+			switch {
+			case issue.MergedAt != nil:
+				result.MergedPullRequests++
+				result.ClosedPullRequests++
+			case closed:
+				result.ClosedPullRequests++
+			default:
+				result.OpenPullRequests++
+			}
+			continue
+		}
+
+		opened = append(opened, issue.CreatedAt)
+		if closed {
+			result.ClosedIssues++
+			if issue.ClosedAt != nil {
+				toClose = append(toClose, issue.ClosedAt.Sub(issue.CreatedAt).Hours())
+			}
+		} else {
+			result.OpenIssues++
+		}
+		if issue.FirstResponseAt != nil {
+			toFirstResponse = append(toFirstResponse, issue.FirstResponseAt.Sub(issue.CreatedAt).Hours())
+		}
+	}
+
+	if result.ClosedPullRequests > 0 {
+		result.PullRequestMergeRate = float64(result.MergedPullRequests) / float64(result.ClosedPullRequests)
+	}
+	result.MedianTimeToCloseHours = median(toClose)
+	result.MedianTimeToFirstResponseHours = median(toFirstResponse)
+	result.IssuesOpenedPerMonth = PeriodCounts(opened, Month)
+	return result
+}
+
+// PeriodCounts buckets the events by period, from the period of the
+// first event up to the current one, oldest first
+func PeriodCounts(events []time.Time, granularity string) []common.PeriodCount {
+	counts := []common.PeriodCount{}
+	if len(events) == 0 {
+		return counts
+	}
+
+	first := events[0]
+	for _, e := range events {
+		if e.Before(first) {
+			first = e
+		}
+	}
+
+	count := Count(events, granularity)
+	for _, p := range Periods(first, time.Now(), granularity) {
+		counts = append(counts, common.PeriodCount{
+			Period: PeriodLabel(p, granularity),
+			Count:  count[p],
+		})
+	}
+	return counts
+}
+
+// median returns the median of the values, or 0 when there are none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
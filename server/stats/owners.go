@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// ownerRanking is how many repositories the owner overview ranks as top
+// growers and as least active
+const ownerRanking = 5
+
+// OwnerStats sums the counters of the repositories of an owner, and ranks
+// them by the stars gained in the last 4 weeks and by how little they
+// were committed to
+func OwnerStats(owner string, repos []common.OwnerRepository, stars, commits []time.Time) common.OwnerStats {
+	result := common.OwnerStats{
+		OwnerName:         owner,
+		TotalRepositories: len(repos),
+		StarsByMonth:      PeriodCounts(stars, Month),
+		CommitsByMonth:    PeriodCounts(commits, Month),
+	}
+	for _, repo := range repos {
+		result.TotalStars += repo.TotalStars
+		result.TotalCommits += repo.TotalCommits
+		result.TotalForks += repo.TotalForks
+	}
+
+	growers := append([]common.OwnerRepository(nil), repos...)
+	sort.SliceStable(growers, func(i, j int) bool {
+		return growers[i].StarsCountLast4Weeks > growers[j].StarsCountLast4Weeks
+	})
+	result.TopGrowers = firstRepos(growers, ownerRanking)
+
+	inactive := append([]common.OwnerRepository(nil), repos...)
+	sort.SliceStable(inactive, func(i, j int) bool {
+		if inactive[i].CommitsCountLast4Weeks != inactive[j].CommitsCountLast4Weeks {
+			return inactive[i].CommitsCountLast4Weeks < inactive[j].CommitsCountLast4Weeks
+		}
+		return inactive[i].CommitsCountLast12Months < inactive[j].CommitsCountLast12Months
+	})
+	result.LeastActive = firstRepos(inactive, ownerRanking)
+	return result
+}
+
+func firstRepos(repos []common.OwnerRepository, n int) []common.OwnerRepository {
+	if len(repos) > n {
+		repos = repos[:n]
+	}
+	return append([]common.OwnerRepository{}, repos...)
+}
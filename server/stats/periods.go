@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+)
+
+// The granularities time series can be bucketed by
+const (
+	Day     = "day"
+	Week    = "week"
+	Month   = "month"
+	Quarter = "quarter"
+	Year    = "year"
+)
+
+// ValidGranularity reports whether the granularity is supported
+func ValidGranularity(granularity string) bool {
+	switch granularity {
+	case Day, Week, Month, Quarter, Year:
+		return true
+	}
+	return false
+}
+
+// PeriodStart returns the beginning of the period `t` falls in, in UTC.
+// Weeks start on Monday, as ISO weeks do
+func PeriodStart(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	switch granularity {
+	case Day:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Week:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case Quarter:
+		month := (t.Month()-1)/3*3 + 1
+		return time.Date(t.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+	case Year:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// NextPeriod returns the beginning of the period following the one
+// starting at `start`
+func NextPeriod(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case Day:
+		return start.AddDate(0, 0, 1)
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Quarter:
+		return start.AddDate(0, 3, 0)
+	case Year:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// PeriodLabel formats the period starting at `start`: `2017-08-30`,
+// `2017-W35`, `2017-08`, `2017-Q3` or `2017`
+func PeriodLabel(start time.Time, granularity string) string {
+	switch granularity {
+	case Day:
+		return start.Format("2006-01-02")
+	case Week:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case Quarter:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())-1)/3+1)
+	case Year:
+		return start.Format("2006")
+	default:
+		return start.Format("2006-01")
+	}
+}
+
+// Periods returns the beginning of every period from the one `from`
+// falls in to the one `to` falls in, both included
+func Periods(from, to time.Time, granularity string) []time.Time {
+	var periods []time.Time
+	last := PeriodStart(to, granularity)
+	for p := PeriodStart(from, granularity); !p.After(last); p = NextPeriod(p, granularity) {
+		periods = append(periods, p)
+	}
+	return periods
+}
+
+// Count buckets the events by period, keyed by the period beginning
+func Count(events []time.Time, granularity string) map[time.Time]int {
+	counts := make(map[time.Time]int)
+	for _, e := range events {
+		counts[PeriodStart(e, granularity)]++
+	}
+	return counts
+}
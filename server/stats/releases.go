@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// ReleaseStats computes the release cadence of a repository from its
+// releases and tags. The latest release is the most recent stable one,
+// or the most recent prerelease if there is no stable release yet
+func ReleaseStats(releases []common.Release) common.ReleaseStats {
+	result := common.ReleaseStats{
+		TotalTags:          len(releases),
+		ReleasesPerQuarter: []common.PeriodCount{},
+	}
+
+	var stable []time.Time
+	var latest, latestPrerelease *common.Release
+	for i := range releases {
+		release := &releases[i]
+		if release.PublishedAt == nil {
+			continue
+		}
+		result.TotalReleases++
+		result.TotalDownloads += release.Downloads
+		if release.Prerelease {
+			if latestPrerelease == nil || release.PublishedAt.After(*latestPrerelease.PublishedAt) {
+				latestPrerelease = release
+			}
+			continue
+		}
+		stable = append(stable, *release.PublishedAt)
+		if latest == nil || release.PublishedAt.After(*latest.PublishedAt) {
+			latest = release
+		}
+	}
+	if latest == nil {
+		latest = latestPrerelease
+	}
+	if latest != nil {
+		l := *latest
+		result.LatestRelease = &l
+	}
+
+	result.ReleasesPerQuarter = PeriodCounts(stable, Quarter)
+	if len(stable) > 1 {
+		sort.Slice(stable, func(i, j int) bool { return stable[i].Before(stable[j]) })
+		span := stable[len(stable)-1].Sub(stable[0])
+		result.AverageDaysBetweenReleases = span.Hours() / 24 / float64(len(stable)-1)
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"math"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+const (
+	// spikeWindow is how many days before a day its baseline is
+	// computed from
+	spikeWindow = 28
+
+	// minBaselineDays is how many days of history a day needs before it
+	// can be a spike
+	minBaselineDays = 14
+
+	// spikeThreshold is how many deviations above its baseline a day
+	// has to be to count as a spike
+	spikeThreshold = 5.0
+
+	// minSpikeCount keeps the days of quiet repositories, where a
+	// handful of events already stands out, from being spikes
+	minSpikeCount = 10
+
+	// madScale makes the median absolute deviation comparable with the
+	// standard deviation of normally distributed counts
+	madScale = 1.4826
+)
+
+// Spikes finds the days the `metric` counted by `dates` jumped well above
+// its usual level. The level of a day is the median of the days before
+// it, and the deviation their median absolute deviation, so the spikes
+// themselves hardly move the baseline of the days after them
+func Spikes(metric string, dates []time.Time) []common.SpikeEvent {
+	spikes := []common.SpikeEvent{}
+	if len(dates) == 0 {
+		return spikes
+	}
+
+	first := dates[0]
+	for _, d := range dates {
+		if d.Before(first) {
+			first = d
+		}
+	}
+	count := Count(dates, Day)
+	days := Periods(first, time.Now(), Day)
+	values := make([]float64, len(days))
+	for i, day := range days {
+		values[i] = float64(count[day])
+	}
+
+	for i := minBaselineDays; i < len(values); i++ {
+		if values[i] < minSpikeCount {
+			continue
+		}
+		start := i - spikeWindow
+		if start < 0 {
+			start = 0
+		}
+		window := values[start:i]
+		baseline := median(window)
+		deviations := make([]float64, len(window))
+		for j, v := range window {
+			deviations[j] = math.Abs(v - baseline)
+		}
+		// counts are whole numbers, a flat history deviates by one at least
+		deviation := madScale * math.Max(median(deviations), 1)
+		magnitude := (values[i] - baseline) / deviation
+		if magnitude < spikeThreshold {
+			continue
+		}
+		spikes = append(spikes, common.SpikeEvent{
+			Metric:    metric,
+			Date:      days[i],
+			Count:     int(values[i]),
+			Baseline:  baseline,
+			Magnitude: magnitude,
+		})
+	}
+	return spikes
+}
+
+// AnnotateSpikes attaches the star spikes to the star buckets of the
+// `granularity` they fall in
+func AnnotateSpikes(buckets []common.StarBucket, spikes []common.SpikeEvent, granularity string) {
+	index := make(map[time.Time]int, len(buckets))
+	for i, bucket := range buckets {
+		index[bucket.Start.UTC()] = i
+	}
+	for _, spike := range spikes {
+		if spike.Metric != "stars" {
+			continue
+		}
+		if i, ok := index[PeriodStart(spike.Date, granularity)]; ok {
+			buckets[i].Events = append(buckets[i].Events, spike)
+		}
+	}
+}
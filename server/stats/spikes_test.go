@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// daily returns `counts[i]` events on the i-th of the days ending today
+func daily(counts []int) []time.Time {
+	first := PeriodStart(time.Now(), Day).AddDate(0, 0, 1-len(counts))
+	var dates []time.Time
+	for i, n := range counts {
+		for j := 0; j < n; j++ {
+			dates = append(dates, first.AddDate(0, 0, i).Add(time.Duration(j)*time.Minute))
+		}
+	}
+	return dates
+}
+
+// series returns `days` days of `base` events, `spike` events on the
+// day `at`, and a cycle of `noise` on top of the base
+func series(days, base, at, spike int, noise []int) []int {
+	counts := make([]int, days)
+	for i := range counts {
+		counts[i] = base
+		if len(noise) > 0 {
+			counts[i] += noise[i%len(noise)]
+		}
+	}
+	if at >= 0 {
+		counts[at] = spike
+	}
+	return counts
+}
+
+func TestSpikes(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		spikes []int
+	}{
+		{"flat", series(60, 20, -1, 0, nil), nil},
+		{"noisy", series(60, 20, -1, 0, []int{-3, 0, 2, 4, -1}), nil},
+		{"spike on a flat series", series(60, 20, 40, 120, nil), []int{40}},
+		{"spike on a noisy series", series(60, 20, 40, 120, []int{-3, 0, 2, 4, -1}), []int{40}},
+		{"two spikes", series(60, 5, 30, 200, nil), []int{30}},
+		{"mild bump", series(60, 20, 40, 26, []int{-3, 0, 2, 4, -1}), nil},
+		{"quiet repository", series(60, 0, 40, 8, nil), nil},
+		{"not enough history", series(10, 0, 5, 300, nil), nil},
+		{"no events", nil, nil},
+	}
+	// two spikes close together: the first does not hide the second
+	tests[4].counts[33] = 180
+	tests[4].spikes = append(tests[4].spikes, 33)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			days := Periods(PeriodStart(time.Now(), Day).AddDate(0, 0, 1-len(test.counts)), time.Now(), Day)
+			spikes := Spikes("stars", daily(test.counts))
+			if len(spikes) != len(test.spikes) {
+				t.Fatalf("got %d spikes, want %d: %+v", len(spikes), len(test.spikes), spikes)
+			}
+			for i, at := range test.spikes {
+				spike := spikes[i]
+				if !spike.Date.Equal(days[at]) {
+					t.Fatalf("got a spike on %v, want it on %v", spike.Date, days[at])
+				}
+				if spike.Metric != "stars" || spike.Count != test.counts[at] {
+					t.Fatalf("got spike %+v, want %d stars", spike, test.counts[at])
+				}
+				if spike.Magnitude < spikeThreshold {
+					t.Fatalf("got magnitude %v, want at least %v", spike.Magnitude, spikeThreshold)
+				}
+			}
+		})
+	}
+
+	// the baseline is the median of the days before the spike
+	spikes := Spikes("stars", daily(series(60, 5, 40, 200, nil)))
+	if len(spikes) != 1 || spikes[0].Baseline != 5 {
+		t.Fatalf("got spikes %+v, want one with baseline 5", spikes)
+	}
+	if want := (200.0 - 5) / madScale; spikes[0].Magnitude != want {
+		t.Fatalf("got magnitude %v, want %v", spikes[0].Magnitude, want)
+	}
+}
+
+func TestAnnotateSpikes(t *testing.T) {
+	monday := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)
+	buckets := []common.StarBucket{
+		{Period: "2024-W19", Start: monday.AddDate(0, 0, -7)},
+		{Period: "2024-W20", Start: monday},
+	}
+	spikes := []common.SpikeEvent{
+		{Metric: "stars", Date: monday.AddDate(0, 0, 3)},
+		{Metric: "commits", Date: monday.AddDate(0, 0, 3)},
+		{Metric: "stars", Date: monday.AddDate(0, 0, -30)},
+	}
+	AnnotateSpikes(buckets, spikes, Week)
+	if len(buckets[0].Events) != 0 {
+		t.Fatalf("got events %+v in the first week, want none", buckets[0].Events)
+	}
+	if len(buckets[1].Events) != 1 || !buckets[1].Events[0].Date.Equal(spikes[0].Date) {
+		t.Fatalf("got events %+v in the second week, want the stars spike of its Thursday", buckets[1].Events)
+	}
+}
@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"github.com/flaviocopes/gitometer/server/common"
+)
+
+// The trends a counter can follow, compared with the previous window
+const (
+	Accelerating = "accelerating"
+	Steady       = "steady"
+	Declining    = "declining"
+)
+
+// steadyThreshold is the percentage change, up or down, within which a
+// counter is considered steady
+const steadyThreshold = 10.0
+
+// Trends compares every counter of the `current` window with the same
+// counter of the `previous` one
+func Trends(window string, current, previous common.ActivityWindow) []common.CounterTrend {
+	counters := []struct {
+		name              string
+		current, previous int
+	}{
+		{"stars", current.Stars, previous.Stars},
+		{"unstars", current.Unstars, previous.Unstars},
+		{"net_stars", current.Stars - current.Unstars, previous.Stars - previous.Unstars},
+		{"commits", current.Commits, previous.Commits},
+		{"forks", current.Forks, previous.Forks},
+		{"issues", current.Issues, previous.Issues},
+		{"pull_requests", current.PullRequests, previous.PullRequests},
+	}
+	trends := make([]common.CounterTrend, 0, len(counters))
+	for _, c := range counters {
+		trends = append(trends, Trend(c.name, window, c.current, c.previous))
+	}
+	return trends
+}
+
+// Trend compares the value of a counter with its previous value
+func Trend(counter, window string, current, previous int) common.CounterTrend {
+	t := common.CounterTrend{
+		Counter:  counter,
+		Window:   window,
+		Current:  current,
+		Previous: previous,
+		Change:   current - previous,
+		Trend:    Steady,
+	}
+	if previous == 0 {
+		if current > 0 {
+			t.Trend = Accelerating
+		} else if current < 0 {
+			t.Trend = Declining
+		}
+		return t
+	}
+
+	// net stars can be negative, so the change is relative to the size
+	// of the previous value
+	percent := float64(t.Change) / abs(float64(previous)) * 100
+	t.PercentChange = &percent
+	switch {
+	case percent > steadyThreshold:
+		t.Trend = Accelerating
+	case percent < -steadyThreshold:
+		t.Trend = Declining
+	}
+	return t
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
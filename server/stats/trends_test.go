@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrend(t *testing.T) {
+	tests := []struct {
+		name              string
+		current, previous int
+		trend             string
+		percent           float64
+	}{
+		{"unchanged", 50, 50, Steady, 0},
+		{"just above the threshold", 111, 100, Accelerating, 11},
+		{"at the threshold", 110, 100, Steady, 10},
+		{"at the threshold down", 90, 100, Steady, -10},
+		{"just below the threshold", 89, 100, Declining, -11},
+		{"doubled", 200, 100, Accelerating, 100},
+		{"stopped", 0, 100, Declining, -100},
+		{"negative getting better", -5, -10, Accelerating, 50},
+		{"negative getting worse", -20, -10, Declining, -100},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trend := Trend("stars", "last_week", test.current, test.previous)
+			if trend.Trend != test.trend {
+				t.Fatalf("got trend %s, want %s", trend.Trend, test.trend)
+			}
+			if trend.Change != test.current-test.previous {
+				t.Fatalf("got change %d, want %d", trend.Change, test.current-test.previous)
+			}
+			if trend.PercentChange == nil || *trend.PercentChange != test.percent {
+				t.Fatalf("got percent change %v, want %v", trend.PercentChange, test.percent)
+			}
+		})
+	}
+}
+
+func TestTrendFromZero(t *testing.T) {
+	tests := []struct {
+		current int
+		trend   string
+	}{
+		{5, Accelerating},
+		{0, Steady},
+		{-3, Declining},
+	}
+	for _, test := range tests {
+		trend := Trend("net_stars", "last_week", test.current, 0)
+		if trend.Trend != test.trend || trend.PercentChange != nil {
+			t.Errorf("Trend(%d, 0) = %s with percent change %v, want %s without", test.current, trend.Trend, trend.PercentChange, test.trend)
+		}
+	}
+}
+
+func TestCounterWindows(t *testing.T) {
+	// a Wednesday: the last complete week ended on Monday 13
+	windows := CounterWindows(time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC))
+	end := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		from time.Time
+	}{
+		{"last_week", end.AddDate(0, 0, -7)},
+		{"last_4_weeks", end.AddDate(0, 0, -28)},
+		{"last_12_months", end.AddDate(0, 0, -364)},
+	}
+	if len(windows) != len(tests) {
+		t.Fatalf("got %d windows, want %d", len(windows), len(tests))
+	}
+	for i, test := range tests {
+		w := windows[i]
+		if w.Name != test.name || !w.From.Equal(test.from) || !w.To.Equal(end) {
+			t.Errorf("got window %s from %v to %v, want %s from %v to %v", w.Name, w.From, w.To, test.name, test.from, end)
+		}
+		previous := w.Previous()
+		if !previous.To.Equal(w.From) || previous.To.Sub(previous.From) != w.To.Sub(w.From) {
+			t.Errorf("got previous window from %v to %v for %s", previous.From, previous.To, w.Name)
+		}
+	}
+
+	week := windows[0]
+	if !week.Contains(week.From) || week.Contains(week.To) || !week.Contains(week.To.Add(-time.Nanosecond)) {
+		t.Fatal("the window does not contain its start and the instants before its end only")
+	}
+}
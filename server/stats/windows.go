@@ -0,0 +1,64 @@
+package stats
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultWindows are the activity windows used when none is asked for
+var DefaultWindows = []string{"7d", "30d", "90d", "365d"}
+
+// WindowStart returns the beginning of the window ending at `end`
+// described by `window`: a number of days, weeks, months or years, like
+// `7d`, `4w`, `3m` or `1y`
+func WindowStart(window string, end time.Time) (time.Time, error) {
+	bad := fmt.Errorf("Bad window %q. Expecting a number followed by d, w, m or y, e.g. 30d", window)
+	if len(window) < 2 {
+		return time.Time{}, bad
+	}
+	n, err := strconv.Atoi(window[:len(window)-1])
+	if err != nil || n < 1 {
+		return time.Time{}, bad
+	}
+	switch window[len(window)-1] {
+	case 'd':
+		return end.AddDate(0, 0, -n), nil
+	case 'w':
+		return end.AddDate(0, 0, -7*n), nil
+	case 'm':
+		return end.AddDate(0, -n, 0), nil
+	case 'y':
+		return end.AddDate(-n, 0, 0), nil
+	}
+	return time.Time{}, bad
+}
+
+// CounterWindow is a window the activity counters of common.Repository
+// are computed on, from From included to To excluded
+type CounterWindow struct {
+	Name     string
+	From, To time.Time
+}
+
+// CounterWindows returns the windows of the activity counters of
+// common.Repository at `t`: the last complete week, and the last 4 and
+// 52 complete weeks. Weeks start on Monday, as they do in PeriodStart
+func CounterWindows(t time.Time) []CounterWindow {
+	end := PeriodStart(t, Week)
+	return []CounterWindow{
+		{"last_week", end.AddDate(0, 0, -7), end},
+		{"last_4_weeks", end.AddDate(0, 0, -7*4), end},
+		{"last_12_months", end.AddDate(0, 0, -7*52), end},
+	}
+}
+
+// Previous returns the window of the same length ending where `w` starts
+func (w CounterWindow) Previous() CounterWindow {
+	return CounterWindow{w.Name, w.From.Add(-w.To.Sub(w.From)), w.From}
+}
+
+// Contains reports whether `t` falls in the window
+func (w CounterWindow) Contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}